@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"time"
+	"tinyrpc/logging"
+)
+
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// ErrPermissionDenied 调用方没有权限调用该方法
+var ErrPermissionDenied = errors.New("auth error: permission denied")
+
+// Authorizer 在调用服务方法前检查调用方的权限，未认证时 p 为 nil
+type Authorizer interface {
+	Authorize(p *Principal, serviceMethod string) error
+}
+
+// Rule 一条访问规则。Principals 和 Roles 都为空时匹配所有调用方（包括未认证的），
+// Principals 中的 "*" 匹配所有认证通过的调用方；Methods 为 path.Match 风格的模式，如 Admin.*
+type Rule struct {
+	Effect     string   `json:"effect"`
+	Principals []string `json:"principals,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	Methods    []string `json:"methods"`
+}
+
+// Policy 访问策略，匹配到任意 deny 规则即拒绝，否则匹配到 allow 规则时允许，
+// 都没有匹配时按 Default 处理，Default 为空时拒绝
+type Policy struct {
+	Default string `json:"default,omitempty"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Validate 检查 effect 和方法模式是否合法
+func (policy *Policy) Validate() error {
+	if policy.Default != "" && policy.Default != Allow && policy.Default != Deny {
+		return errors.New("auth error: invalid default effect " + policy.Default)
+	}
+	for _, rule := range policy.Rules {
+		if rule.Effect != Allow && rule.Effect != Deny {
+			return errors.New("auth error: invalid effect " + rule.Effect)
+		}
+		for _, pattern := range rule.Methods {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.New("auth error: invalid method pattern " + pattern)
+			}
+		}
+	}
+	return nil
+}
+
+func (rule *Rule) matchPrincipal(p *Principal) bool {
+	if len(rule.Principals) == 0 && len(rule.Roles) == 0 {
+		return true
+	}
+	if p == nil {
+		return false
+	}
+	for _, name := range rule.Principals {
+		if name == "*" || name == p.Name {
+			return true
+		}
+	}
+	for _, role := range rule.Roles {
+		if p.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *Rule) matchMethod(serviceMethod string) bool {
+	for _, pattern := range rule.Methods {
+		if ok, _ := path.Match(pattern, serviceMethod); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy *Policy) allowed(p *Principal, serviceMethod string) bool {
+	allowed := policy.Default == Allow
+	matched := false
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if !rule.matchMethod(serviceMethod) || !rule.matchPrincipal(p) {
+			continue
+		}
+		if rule.Effect == Deny {
+			return false
+		}
+		matched = true
+	}
+	return allowed || matched
+}
+
+// LoadPolicy 从 JSON 文件读取访问策略
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parsePolicy(data)
+}
+
+func parsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, errors.New("auth error: parse policy: " + err.Error())
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ACL 基于 Policy 的 Authorizer，策略可以在运行时替换
+type ACL struct {
+	mu     sync.RWMutex
+	policy *Policy
+}
+
+func NewACL(policy *Policy) (*ACL, error) {
+	acl := &ACL{}
+	if err := acl.Update(policy); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// LoadACL 从文件创建 ACL，配合 Watch 可以在文件修改后自动重新加载
+func LoadACL(file string) (*ACL, error) {
+	policy, err := LoadPolicy(file)
+	if err != nil {
+		return nil, err
+	}
+	return NewACL(policy)
+}
+
+// Update 替换当前策略，策略不合法时保留原来的策略
+func (acl *ACL) Update(policy *Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+	acl.policy = policy
+	return nil
+}
+
+func (acl *ACL) Authorize(p *Principal, serviceMethod string) error {
+	acl.mu.RLock()
+	policy := acl.policy
+	acl.mu.RUnlock()
+	if !policy.allowed(p, serviceMethod) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// Watch 每隔 interval 检查一次文件，内容变化时重新加载，加载失败时保留原来的策略。
+// 调用返回的函数停止检查
+func (acl *ACL) Watch(file string, interval time.Duration) (stop func()) {
+	last, _ := os.ReadFile(file)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			data, err := os.ReadFile(file)
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			policy, err := parsePolicy(data)
+			if err == nil {
+				err = acl.Update(policy)
+			}
+			if err != nil {
+				logging.Default().Warn("auth error: reload policy", "file", file, "err", err)
+				continue
+			}
+			logging.Default().Info("auth: reloaded policy", "file", file)
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPolicy(t *testing.T) {
+	acl, err := NewACL(&Policy{Rules: []Rule{
+		{Effect: Allow, Methods: []string{"Health.*"}},
+		{Effect: Allow, Principals: []string{"*"}, Methods: []string{"Arith.*"}},
+		{Effect: Allow, Roles: []string{"admin"}, Methods: []string{"Admin.*"}},
+		{Effect: Deny, Principals: []string{"mallory"}, Methods: []string{"*"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := &Principal{Name: "alice", Roles: []string{"admin"}}
+	bob := &Principal{Name: "bob"}
+	mallory := &Principal{Name: "mallory", Roles: []string{"admin"}}
+	cases := []struct {
+		p       *Principal
+		method  string
+		allowed bool
+	}{
+		{nil, "Health.Check", true},
+		{nil, "Arith.Add", false},
+		{bob, "Arith.Add", true},
+		{bob, "Admin.Reset", false},
+		{alice, "Admin.Reset", true},
+		{alice, "Other.Call", false},
+		{mallory, "Admin.Reset", false},
+		{mallory, "Health.Check", false},
+	}
+	for _, c := range cases {
+		if err := acl.Authorize(c.p, c.method); (err == nil) != c.allowed {
+			t.Errorf("%v %s: %v", c.p, c.method, err)
+		}
+	}
+
+	if err := acl.Update(&Policy{Default: Allow}); err != nil {
+		t.Fatal(err)
+	}
+	if err := acl.Authorize(nil, "Other.Call"); err != nil {
+		t.Fatal(err)
+	}
+	if err := acl.Update(&Policy{Rules: []Rule{{Effect: "maybe"}}}); err == nil {
+		t.Fatal("invalid effect should be rejected")
+	}
+	if err := acl.Update(&Policy{Rules: []Rule{{Effect: Allow, Methods: []string{"["}}}}); err == nil {
+		t.Fatal("invalid pattern should be rejected")
+	}
+}
+
+func TestACLWatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	write := func(s string) {
+		if err := os.WriteFile(file, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"rules":[{"effect":"allow","principals":["*"],"methods":["Arith.*"]}]}`)
+	acl, err := LoadACL(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := acl.Watch(file, 10*time.Millisecond)
+	defer stop()
+	bob := &Principal{Name: "bob"}
+	if err := acl.Authorize(bob, "Arith.Add"); err != nil {
+		t.Fatal(err)
+	}
+
+	write(`{"rules":[{"effect":"deny","principals":["bob"],"methods":["Arith.Add"]}],"default":"allow"}`)
+	waitFor(t, func() bool { return acl.Authorize(bob, "Arith.Add") == ErrPermissionDenied })
+
+	// 格式错误的文件不会替换当前策略
+	write(`{"rules":`)
+	time.Sleep(50 * time.Millisecond)
+	if err := acl.Authorize(bob, "Arith.Mul"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+auth 连接级别的认证：客户端在握手参数 ConArgs 中提交凭证，
+服务端的 Authenticator 校验后得到 Principal，handler 和拦截器通过 FromContext 读取
+*/
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	TypeKey    = "type"
+	TypeBearer = "bearer"
+	TypeHMAC   = "hmac"
+)
+
+// Principal 认证通过的调用方
+type Principal struct {
+	Name  string
+	Roles []string
+}
+
+// HasRole 判断调用方是否具有某个角色
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Credentials 客户端凭证，每次建立连接时调用一次，生成握手时提交的字段
+type Credentials interface {
+	Credentials() (map[string]string, error)
+}
+
+// Authenticator 服务端校验握手时提交的凭证，失败时返回错误，连接会被关闭
+type Authenticator interface {
+	Authenticate(credentials map[string]string) (*Principal, error)
+}
+
+var (
+	ErrMissingCredentials = errors.New("auth error: missing credentials")
+	ErrInvalidCredentials = errors.New("auth error: invalid credentials")
+)
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 返回当前请求认证通过的调用方
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+type bearerToken string
+
+// BearerToken 使用固定的 token 认证
+func BearerToken(token string) Credentials {
+	return bearerToken(token)
+}
+
+func (t bearerToken) Credentials() (map[string]string, error) {
+	return map[string]string{TypeKey: TypeBearer, "token": string(t)}, nil
+}
+
+// TokenAuthenticator token -> 调用方
+type TokenAuthenticator map[string]*Principal
+
+func (ta TokenAuthenticator) Authenticate(credentials map[string]string) (*Principal, error) {
+	if credentials[TypeKey] != TypeBearer {
+		return nil, ErrMissingCredentials
+	}
+	p, ok := ta[credentials["token"]]
+	if !ok || credentials["token"] == "" {
+		return nil, ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+type hmacCredentials struct {
+	keyID  string
+	secret []byte
+}
+
+// HMAC 每次建立连接时生成随机 nonce，用共享密钥对 keyID、nonce 和时间戳签名，密钥本身不会被发送
+func HMAC(keyID string, secret []byte) Credentials {
+	return &hmacCredentials{keyID: keyID, secret: secret}
+}
+
+func (h *hmacCredentials) Credentials() (map[string]string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	nonce := hex.EncodeToString(buf)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return map[string]string{
+		TypeKey:     TypeHMAC,
+		"key-id":    h.keyID,
+		"nonce":     nonce,
+		"timestamp": timestamp,
+		"signature": sign(h.secret, h.keyID, nonce, timestamp),
+	}, nil
+}
+
+func sign(secret []byte, keyID string, nonce string, timestamp string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(keyID + "\n" + nonce + "\n" + timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HMACKey 服务端保存的共享密钥以及对应的调用方
+type HMACKey struct {
+	Secret    []byte
+	Principal *Principal
+}
+
+// HMACAuthenticator 校验签名和时间戳，并拒绝在有效期内重复使用的 nonce
+type HMACAuthenticator struct {
+	keys    map[string]HMACKey
+	maxSkew time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+const defaultMaxSkew = 5 * time.Minute
+
+// NewHMACAuthenticator maxSkew 为允许的时钟偏差，为 0 时使用 5 分钟
+func NewHMACAuthenticator(keys map[string]HMACKey, maxSkew time.Duration) *HMACAuthenticator {
+	if maxSkew == 0 {
+		maxSkew = defaultMaxSkew
+	}
+	return &HMACAuthenticator{
+		keys:    keys,
+		maxSkew: maxSkew,
+		nonces:  make(map[string]time.Time),
+	}
+}
+
+func (ha *HMACAuthenticator) Authenticate(credentials map[string]string) (*Principal, error) {
+	if credentials[TypeKey] != TypeHMAC {
+		return nil, ErrMissingCredentials
+	}
+	key, ok := ha.keys[credentials["key-id"]]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	nonce, timestamp := credentials["nonce"], credentials["timestamp"]
+	want := sign(key.Secret, credentials["key-id"], nonce, timestamp)
+	if nonce == "" || !hmac.Equal([]byte(want), []byte(credentials["signature"])) {
+		return nil, ErrInvalidCredentials
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	now := time.Now()
+	if t := time.Unix(sec, 0); t.Before(now.Add(-ha.maxSkew)) || t.After(now.Add(ha.maxSkew)) {
+		return nil, errors.New("auth error: credentials expired")
+	}
+
+	ha.mu.Lock()
+	defer ha.mu.Unlock()
+	for n, expire := range ha.nonces {
+		if expire.Before(now) {
+			delete(ha.nonces, n)
+		}
+	}
+	if _, used := ha.nonces[nonce]; used {
+		return nil, errors.New("auth error: nonce reused")
+	}
+	ha.nonces[nonce] = now.Add(2 * ha.maxSkew)
+	return key.Principal, nil
+}
+
+// Any 依次尝试多个 Authenticator，凭证类型不匹配时尝试下一个
+type Any []Authenticator
+
+func (a Any) Authenticate(credentials map[string]string) (*Principal, error) {
+	for _, authenticator := range a {
+		p, err := authenticator.Authenticate(credentials)
+		if err != ErrMissingCredentials {
+			return p, err
+		}
+	}
+	return nil, ErrMissingCredentials
+}
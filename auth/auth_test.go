@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHMACAuthenticator(t *testing.T) {
+	secret := []byte("secret")
+	p := &Principal{Name: "billing"}
+	a := NewHMACAuthenticator(map[string]HMACKey{"k1": {Secret: secret, Principal: p}}, time.Minute)
+
+	creds, err := HMAC("k1", secret).Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.Authenticate(creds); err != nil || got != p {
+		t.Fatalf("authenticate: %v %v", got, err)
+	}
+	if _, err := a.Authenticate(creds); err == nil {
+		t.Fatal("reused nonce should be rejected")
+	}
+
+	creds, _ = HMAC("k1", secret).Credentials()
+	creds["timestamp"] = "1"
+	if _, err := a.Authenticate(creds); err != ErrInvalidCredentials {
+		t.Fatalf("tampered timestamp: %v", err)
+	}
+	creds, _ = HMAC("k2", secret).Credentials()
+	if _, err := a.Authenticate(creds); err != ErrInvalidCredentials {
+		t.Fatalf("unknown key: %v", err)
+	}
+	if _, err := a.Authenticate(map[string]string{TypeKey: TypeBearer}); err != ErrMissingCredentials {
+		t.Fatalf("bearer credentials: %v", err)
+	}
+}
+
+func TestAny(t *testing.T) {
+	p := &Principal{Name: "alice"}
+	a := Any{NewHMACAuthenticator(nil, 0), TokenAuthenticator{"t": p}}
+	creds, _ := BearerToken("t").Credentials()
+	if got, err := a.Authenticate(creds); err != nil || got != p {
+		t.Fatalf("authenticate: %v %v", got, err)
+	}
+	if _, err := a.Authenticate(nil); err != ErrMissingCredentials {
+		t.Fatalf("no credentials: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+	"tinyrpc/logging"
+)
+
+// CertReloader 从文件加载证书，证书更新后调用 Reload 或 Watch 即可生效，不需要重启。
+// 服务端设置 tls.Config.GetCertificate，客户端设置 tls.Config.GetClientCertificate
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 重新读取证书和私钥，失败时保留原来的证书
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.New("auth error: load certificate: " + err.Error())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	return nil
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch 每隔 interval 检查证书文件的修改时间，变化时重新加载。调用返回的函数停止检查
+func (r *CertReloader) Watch(interval time.Duration) (stop func()) {
+	last := r.modTime()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			mod := r.modTime()
+			if mod.Equal(last) {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				// 证书和私钥可能还没有全部写完，下次再试
+				logging.Default().Warn("auth error: reload certificate", "file", r.certFile, "err", err)
+				continue
+			}
+			last = mod
+			logging.Default().Info("auth: reloaded certificate", "file", r.certFile)
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// modTime 证书和私钥中较新的修改时间
+func (r *CertReloader) modTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// PrincipalFromCertificate 使用证书的 CommonName 作为调用方名称，为空时依次使用第一个 URI 和 DNS SAN
+func PrincipalFromCertificate(cert *x509.Certificate) *Principal {
+	name := cert.Subject.CommonName
+	if name == "" && len(cert.URIs) > 0 {
+		name = cert.URIs[0].String()
+	}
+	if name == "" && len(cert.DNSNames) > 0 {
+		name = cert.DNSNames[0]
+	}
+	return &Principal{Name: name, Roles: cert.Subject.OrganizationalUnit}
+}
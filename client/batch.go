@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"tinyrpc/codec"
+	"tinyrpc/metadata"
+	"tinyrpc/status"
+)
+
+// Batch 收集多个调用，Send 时作为一个批量调用在一次写入中发送，
+// 服务端并发处理这些调用并分别回复，每个调用的结果通过各自的 Call 返回。Batch 不能并发使用
+type Batch struct {
+	client *Client
+	calls  []*Call
+}
+
+// NewBatch 创建批量调用
+func (client *Client) NewBatch() *Batch {
+	return &Batch{client: client}
+}
+
+// Add 与 Go 的参数相同，调用在 Send 时才会发送
+func (b *Batch) Add(serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
+	return b.AddContext(context.Background(), serviceMethod, argv, reply, done)
+}
+
+// AddContext 与 Add 相同，并发送 ctx 中通过 metadata.NewOutgoingContext 附加的元数据
+func (b *Batch) AddContext(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
+	if done == nil || cap(done) == 0 {
+		done = make(chan *Call, 1)
+	}
+	call := NewCall(serviceMethod, argv, reply, done)
+	call.Metadata, _ = metadata.FromOutgoingContext(ctx)
+	call.observe = b.client.startCall(ctx, call)
+	call.resolved = make(chan struct{})
+	b.calls = append(b.calls, call)
+	return call
+}
+
+// Len 已经添加的调用数
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// Send 发送已经添加的调用并清空 Batch，不等待回复。
+// 超过 WithMaxPendingCalls 等限制的调用不会发送，对应的 Call 直接带有错误
+func (b *Batch) Send() {
+	calls := b.calls
+	b.calls = nil
+	client := b.client
+	sending := make([]*Call, 0, len(calls))
+	for _, call := range calls {
+		err := client.acquire(context.Background(), false)
+		if err == nil {
+			if err = client.addCall(call); err != nil {
+				client.release(1)
+			}
+		}
+		if err != nil {
+			call.Error = err
+			call.done()
+			continue
+		}
+		sending = append(sending, call)
+	}
+	if len(sending) == 0 {
+		return
+	}
+	if err := client.sendBatch(sending); err != nil {
+		// 批量调用没有完整发送，编码器的状态也可能已经与服务端不一致，连接不能继续使用
+		client.log().Warn("client error: send batch", "peer", client.remoteAddr, "err", err)
+		client.fail(status.New(status.Unavailable, "client error: send batch: "+err.Error()))
+	}
+}
+
+// Do 发送已经添加的调用并等待全部回复，返回第一个失败的调用的错误。
+// Do 不读取调用的 Done，每个调用结束时仍然会发送到各自的 Done。
+// ctx 结束时还未回复的调用都返回超时或取消的错误
+func (b *Batch) Do(ctx context.Context) error {
+	calls := b.calls
+	b.Send()
+	var first error
+	for i, call := range calls {
+		select {
+		case <-call.resolved:
+		case <-ctx.Done():
+			err := status.New(status.DeadlineExceeded, "client error: batch timeout")
+			if ctx.Err() == context.Canceled {
+				err = status.New(status.Canceled, "client error: batch canceled")
+			}
+			for _, call := range calls[i:] {
+				// 已经结束或正在由接收协程结束的调用不会被移除
+				if call := b.client.removeCall(call.Num); call != nil {
+					call.Error = err
+					call.done()
+				}
+			}
+			return err
+		}
+		if first == nil && call.Error != nil {
+			first = call.Error
+		}
+	}
+	return first
+}
+
+// sendBatch 在一次写入中发送批量调用的头部和所有请求。
+// 超过大小限制的请求返回错误，其余的请求重新编码后发送，批量调用的头部需要正确的请求数
+func (client *Client) sendBatch(calls []*Call) error {
+	client.send.Lock()
+	defer client.send.Unlock()
+	client.limited.Hold()
+	for len(calls) > 0 {
+		oversized, err := client.writeBatch(calls)
+		if err != nil {
+			client.limited.Discard()
+			_ = client.limited.Flush()
+			return err
+		}
+		if oversized < 0 {
+			break
+		}
+		client.limited.Discard()
+		if call := client.removeCall(calls[oversized].Num); call != nil {
+			call.Error = client.requestTooLarge()
+			call.done()
+		}
+		calls = append(calls[:oversized:oversized], calls[oversized+1:]...)
+	}
+	client.log().Debug("client: send batch", "calls", len(calls))
+	return client.limited.Flush()
+}
+
+// writeBatch 编码批量调用，返回第一个超过大小限制的请求的位置，没有时为 -1
+func (client *Client) writeBatch(calls []*Call) (int, error) {
+	if err := client.codecc.WriteHeader(codec.Header{ServiceMethod: codec.BatchMethod}); err != nil {
+		return -1, err
+	}
+	if err := client.codecc.WriteBody(len(calls)); err != nil {
+		return -1, err
+	}
+	sizes := make([]int64, len(calls))
+	for i, call := range calls {
+		client.limited.BeginMessage(client.maxRequestSize)
+		header := codec.Header{ServiceMethod: call.ServerMethod, Num: call.Num, Metadata: call.Metadata}
+		if err := client.codecc.WriteHeader(header); err != nil {
+			return -1, err
+		}
+		if err := client.codecc.WriteBody(call.Argv); err != nil {
+			return -1, err
+		}
+		size, err := client.limited.EndMessage()
+		if err != nil {
+			return i, nil
+		}
+		sizes[i] = size
+	}
+	for i, call := range calls {
+		client.metrics.observeSize(client.metrics.reqSize, call.ServerMethod, sizes[i])
+	}
+	return -1, nil
+}
@@ -1,5 +1,10 @@
 package client
 
+import (
+	"sync"
+	"tinyrpc/metadata"
+)
+
 type Call struct {
 	Num          uint64
 	ServerMethod string
@@ -7,13 +12,32 @@ type Call struct {
 	Reply        interface{}
 	Error        error
 	Done         chan *Call
+	Metadata     metadata.MD // 随请求发送的元数据
+	Trailer      metadata.MD // 服务端随响应返回的元数据
+
+	observe  func(err error) // 上报调用结果，只调用一次
+	once     sync.Once
+	resolved chan struct{} // 批量调用中 Batch.Do 通过它等待调用结束，不占用调用方的 Done
 }
 
 // done 利用channel异步通知当前调用结束
 func (call *Call) done() {
+	call.finish(call.Error)
+	if call.resolved != nil {
+		close(call.resolved)
+	}
 	call.Done <- call
 }
 
+// finish 上报调用结果，Call 等待超时时调用已经不会再 done
+func (call *Call) finish(err error) {
+	call.once.Do(func() {
+		if call.observe != nil {
+			call.observe(err)
+		}
+	})
+}
+
 func NewCall(serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
 	return &Call{
 		ServerMethod: serviceMethod,
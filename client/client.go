@@ -2,14 +2,27 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
-	"log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
+	"tinyrpc/auth"
 	"tinyrpc/codec"
+	"tinyrpc/logging"
+	"tinyrpc/metadata"
+	"tinyrpc/metrics"
+	"tinyrpc/status"
+	"tinyrpc/trace"
 )
 
+// Caller 同步调用的接口，Client 和 DClient 都实现了该接口，生成的客户端代码依赖于它
+type Caller interface {
+	Call(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}) error
+}
+
 // Client rpc_client 负责接收和转发数据
 type Client struct {
 	num       uint64
@@ -19,13 +32,38 @@ type Client struct {
 	clientMux sync.Mutex
 	callQueue map[uint64]*Call
 	closing   bool
+	closeErr  error // 连接因错误关闭时的原因，之后的调用都返回该错误
+
+	credentials auth.Credentials
+	tlsConfig   *tls.Config
+	provider    metrics.Provider
+	metrics     *clientMetrics
+	tracer      *trace.Tracer
+	logger      logging.Logger
+	remoteAddr  string
+
+	limited         *codec.LimitConn
+	maxRequestSize  int64
+	maxResponseSize int64
+
+	retry      RetryPolicy
+	maxPending int
+	slots      chan struct{} // 配置了 maxPending 时每个等待回复的调用占用一个名额
+
+	keepaliveInterval time.Duration
+	keepaliveMissed   int
+	pong              int32 // 原子操作，上次检查后收到过 pong 时为 1
+	pinging           int32 // 原子操作，ping 正在发送时为 1
 }
 
 func (client *Client) Close() error {
 	client.clientMux.Lock()
 	defer client.clientMux.Unlock()
 
-	client.closing = true
+	if !client.closing {
+		client.closing = true
+		client.metrics.connections.Add(-1)
+	}
 	//client.broadcastCall(errors.New("client has been closed"))
 	return client.codecc.Close()
 }
@@ -36,54 +74,110 @@ func (client *Client) addCall(call *Call) error {
 	defer client.clientMux.Unlock()
 
 	if client.isClosing() {
+		if client.closeErr != nil {
+			return client.closeErr
+		}
 		return errors.New("client error: client is closing")
 	}
 	call.Num = client.num
 	client.callQueue[client.num] = call
 	client.num++
+	client.metrics.pending.Add(1)
 	return nil
 }
 
-func (client *Client) findCall(num uint64) *Call {
-	client.clientMux.Lock()
-	defer client.clientMux.Unlock()
-	return client.callQueue[num]
-}
-
-func (client *Client) removeCall(num uint64) error {
+// removeCall 从 callQueue 中移除调用并返回，调用已经被移除或连接正在关闭时返回 nil。
+// 只有成功移除调用的一方负责结束该调用，避免同一个调用被通知两次
+func (client *Client) removeCall(num uint64) *Call {
 	client.clientMux.Lock()
 	defer client.clientMux.Unlock()
 	if client.isClosing() {
-		return errors.New("client error: client is closing")
+		return nil
+	}
+	call, ok := client.callQueue[num]
+	if !ok {
+		return nil
 	}
 	delete(client.callQueue, num)
-	return nil
+	client.metrics.pending.Add(-1)
+	client.release(1)
+	return call
 }
+
+// broadcastCall 连接断开时结束所有等待中的调用，已经记录过关闭原因时使用最先记录的原因
 func (client *Client) broadcastCall(err error) {
 	client.clientMux.Lock()
 	defer client.clientMux.Unlock()
 	client.send.Lock()
 	defer client.send.Unlock()
+	if client.closeErr != nil {
+		err = client.closeErr
+	}
 	for _, call := range client.callQueue {
 		call.Error = err
 		call.done()
 	}
+	client.metrics.pending.Add(-float64(len(client.callQueue)))
+	client.release(len(client.callQueue))
+	client.callQueue = make(map[uint64]*Call)
+	if !client.closing {
+		client.metrics.connections.Add(-1)
+	}
 	client.closing = true
+	client.closeErr = err
 }
 func (client *Client) isClosing() bool {
 	return client.closing
 }
-func NewClient(conn net.Conn) *Client {
+
+// IsAvailable 连接未关闭时返回 true
+func (client *Client) IsAvailable() bool {
+	client.clientMux.Lock()
+	defer client.clientMux.Unlock()
+	return !client.closing
+}
+
+func NewClient(conn net.Conn, opts ...Option) *Client {
+	return newClient(conn, applyOptions(opts))
+}
+
+// newClient 使用已经应用了配置的 client 创建连接，client 不能再用于其他连接
+func newClient(conn net.Conn, client *Client) *Client {
+	client.metrics = newClientMetrics(client.provider)
+	client.remoteAddr = conn.RemoteAddr().String()
+	conn = metrics.MeterConn(conn, client.metrics.bytesIn, client.metrics.bytesOut)
+	f := codec.MakeCodecFuncMap[client.conArgs.CodecType]
+	if f == nil {
+		return nil
+	}
+	client.limited = codec.NewLimitConn(conn, client.conArgs.CodecType, client.maxResponseSize)
+	client.codecc = f(client.limited)
+	if client.codecc == nil {
+		return nil
+	}
+	client.metrics.connections.Add(1)
+	return client
+}
+
+// log 没有通过 WithLogger 配置时使用 logging.Default()
+func (client *Client) log() logging.Logger {
+	if client.logger != nil {
+		return client.logger
+	}
+	return logging.Default()
+}
+
+func applyOptions(opts []Option) *Client {
 	client := &Client{
 		num:       1,
 		conArgs:   *codec.DefaultConArgs,
 		callQueue: make(map[uint64]*Call),
 	}
-
-	f := codec.MakeCodecFuncMap[client.conArgs.CodecType]
-	client.codecc = f(conn)
-	if client.codecc == nil {
-		return nil
+	for _, opt := range opts {
+		opt(client)
+	}
+	if client.maxPending > 0 {
+		client.slots = make(chan struct{}, client.maxPending)
 	}
 	return client
 }
@@ -93,32 +187,53 @@ func (client *Client) receive() {
 	var err error
 	for {
 		if err != nil {
-			log.Println("client error :", err)
+			if client.IsAvailable() {
+				client.log().Warn("client error: connection closed", "peer", client.remoteAddr, "err", err)
+			} else {
+				client.log().Debug("client: connection closed", "peer", client.remoteAddr)
+			}
 			break
 		}
 		var header codec.Header
+		client.limited.Reset()
 		// 读入 header 中出错 不必接着读入body
 		if err = client.codecc.ReadHeader(&header); err != nil {
+			err = client.limitError(err)
 			continue
 		}
-		call := client.findCall(header.Num)
-		if call != nil {
-			_ = client.removeCall(header.Num)
-		} else {
-			log.Println("the call has been removed")
+		// Num 为 0 的响应是连接级别的消息，例如 pong 和认证失败
+		if header.Num == 0 {
+			if header.ServiceMethod == codec.PongMethod {
+				atomic.StoreInt32(&client.pong, 1)
+			}
+			if err = client.codecc.ReadBody(nil); err == nil && header.Error != "" {
+				err = headerError(&header)
+			}
+			continue
+		}
+		call := client.removeCall(header.Num)
+		if call == nil {
+			// 调用已经超时被移除，丢弃 body
+			client.log().Debug("client: reply of a removed call", "num", header.Num)
+			err = client.limitError(client.codecc.ReadBody(nil))
 			continue
 		}
+		call.Trailer = header.Metadata
 		//header
-		if header.Error != nil {
-			err = errors.New("client error: read header error " + err.Error())
-			call.Error = err
+		if header.Error != "" {
+			call.Error = headerError(&header)
+			err = client.limitError(client.codecc.ReadBody(nil))
 			call.done()
 			continue
 		}
 		if err = client.codecc.ReadBody(call.Reply); err != nil {
 			call.Error = errors.New("client error:reading body" + err.Error())
+			if err = client.limitError(err); client.limited.Exceeded() {
+				call.Error = err
+			}
+		} else {
+			client.metrics.observeSize(client.metrics.respSize, call.ServerMethod, client.limited.ReadSize())
 		}
-		log.Println("client read reply")
 		call.done()
 	}
 	if err != nil {
@@ -127,29 +242,129 @@ func (client *Client) receive() {
 }
 
 // Dial 用于建立rpc_client与server 的连接,通过返回的Client可以同/异步调用服务端注册的方法。
-func Dial(network string, addr string) (*Client, error) {
-	conn, err := net.Dial(network, addr)
-	//defer func() { _ = conn.Close() }()注意这里不要随手close掉。。。。
+// network 可以是 net.Dial 支持的任意流式网络，如 tcp 或 Unix 域套接字 unix（addr 为套接字文件路径）
+func Dial(network string, addr string, opts ...Option) (*Client, error) {
+	return DialTimeout(network, addr, 0, opts...)
+}
+
+// DialTimeout 与 Dial 相同，建立连接最多等待 timeout，为 0 时不限制
+func DialTimeout(network string, addr string, timeout time.Duration, opts ...Option) (*Client, error) {
+	options := applyOptions(opts)
+	conn, err := dialConn(network, addr, timeout, options)
 	if err != nil {
-		log.Println("client error:client connection error", err)
 		return nil, err
 	}
-	// 先进行协议上的沟通，出于方便同一采用defaultConArgs
-	err = json.NewEncoder(conn).Encode(codec.DefaultConArgs)
-	log.Println("client send conArgs-------", codec.DefaultConArgs)
+	return newClientConn(conn, options)
+}
+
+// dialConn 建立连接，设置了 TLS 时完成握手，options 为已经应用的配置
+func dialConn(network string, addr string, timeout time.Duration, options *Client) (net.Conn, error) {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	//defer func() { _ = conn.Close() }()注意这里不要随手close掉。。。。
 	if err != nil {
+		options.log().Warn("client error: dial", "addr", addr, "err", err)
 		return nil, err
 	}
+	if config := options.tlsConfig; config != nil {
+		if conn, err = tlsHandshake(conn, addr, config, timeout); err != nil {
+			options.log().Warn("client error: tls handshake", "addr", addr, "err", err)
+			return nil, err
+		}
+	}
+	return conn, nil
+}
 
-	client := NewClient(conn)
+// DialTLS 与 Dial 相同，通过 TLS 建立连接
+func DialTLS(network string, addr string, config *tls.Config, opts ...Option) (*Client, error) {
+	return Dial(network, addr, append(opts, WithTLSConfig(config))...)
+}
+
+// tlsHandshake 在已经建立的连接上完成 TLS 握手，timeout 为 0 时不限制
+func tlsHandshake(conn net.Conn, addr string, config *tls.Config, timeout time.Duration) (net.Conn, error) {
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// newClientConn 在已经建立的连接上完成协议协商并开始接收数据，options 成为返回的 Client
+func newClientConn(conn net.Conn, options *Client) (*Client, error) {
+	client := newClient(conn, options)
 	if client == nil {
-		log.Println("client error:new client failed")
+		options.log().Error("client error: new client failed", "codec", options.conArgs.CodecType)
+		_ = conn.Close()
 		return nil, errors.New("new client failed")
 	}
+	if client.credentials != nil {
+		credentials, err := client.credentials.Credentials()
+		if err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+		client.conArgs.Credentials = credentials
+	}
+	// 先进行协议上的沟通，codec 创建后还未写入数据，握手参数一定在最前面
+	err := json.NewEncoder(conn).Encode(client.conArgs)
+	client.log().Debug("client: send conArgs", "protocol", client.conArgs.Protocol, "codec", client.conArgs.CodecType)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	if client.conArgs.Credentials != nil {
+		if err := client.readAuthResult(); err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+	}
 	go client.receive()
+	if client.keepaliveInterval > 0 {
+		go client.keepalive()
+	}
 	return client, nil
 }
 
+// readAuthResult 读取服务端用 Num 为 0 的 header 返回的认证结果
+func (client *Client) readAuthResult() error {
+	var header codec.Header
+	if err := client.codecc.ReadHeader(&header); err != nil {
+		return err
+	}
+	if err := client.codecc.ReadBody(nil); err != nil {
+		return err
+	}
+	if header.Error != "" {
+		return headerError(&header)
+	}
+	return nil
+}
+
+// headerError 将响应 header 中的错误还原为带错误码的错误
+func headerError(header *codec.Header) error {
+	code := status.Code(header.Code)
+	if code == status.OK {
+		code = status.Unknown
+	}
+	err := status.New(code, header.Error)
+	err.RetryAfter = time.Duration(header.RetryAfter) * time.Millisecond
+	return err
+}
+
 func (client *Client) sendCall(call *Call) {
 	client.send.Lock()
 	defer client.send.Unlock()
@@ -157,24 +372,35 @@ func (client *Client) sendCall(call *Call) {
 	header := &codec.Header{
 		ServiceMethod: call.ServerMethod,
 		Num:           call.Num,
+		Metadata:      call.Metadata,
 	}
-	log.Println("client send header", header)
-	if err := client.codecc.WriteHeader(*header); err != nil {
-		_ = client.removeCall(call.Num)
-		if call != nil {
-			call.Error = err
-			call.done()
-		}
-		return
+	client.log().Debug("client: send request", "method", header.ServiceMethod, "num", header.Num)
+	// 先缓存编码后的请求，超过大小限制或编码失败时不写入连接，连接可以继续使用
+	client.limited.Hold()
+	client.limited.BeginMessage(client.maxRequestSize)
+	err := client.codecc.WriteHeader(*header)
+	if err == nil {
+		err = client.codecc.WriteBody(call.Argv)
 	}
-	if err := client.codecc.WriteBody(call.Argv); err != nil {
-		_ = client.removeCall(call.Num)
-		if call != nil {
+	if err != nil {
+		client.limited.DiscardMessage()
+	}
+	size, sizeErr := client.limited.EndMessage()
+	if err == nil && sizeErr != nil {
+		err = client.requestTooLarge()
+	}
+	if flushErr := client.limited.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		// 调用可能已经超时被移除，或者连接关闭时由 broadcastCall 结束
+		if client.removeCall(call.Num) != nil {
 			call.Error = err
 			call.done()
 		}
 		return
 	}
+	client.metrics.observeSize(client.metrics.reqSize, call.ServerMethod, size)
 }
 
 // Go invokes the function asynchronously. It returns the Call structure representing
@@ -182,11 +408,29 @@ func (client *Client) sendCall(call *Call) {
 // the same Call object. If done is nil, Go will allocate a new channel.
 // If non-nil, done must be buffered or Go will deliberately crash.
 func (client *Client) Go(serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
+	return client.GoContext(context.Background(), serviceMethod, argv, reply, done)
+}
+
+// GoContext 与 Go 相同，并发送 ctx 中通过 metadata.NewOutgoingContext 附加的元数据
+func (client *Client) GoContext(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
+	return client.goContext(ctx, serviceMethod, argv, reply, done, false)
+}
+
+// goContext block 为 true 时等待直到有空闲的名额，见 WithMaxPendingCalls
+func (client *Client) goContext(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}, done chan *Call, block bool) *Call {
 	if done == nil || cap(done) == 0 {
 		done = make(chan *Call, 1)
 	}
 	call := NewCall(serviceMethod, argv, reply, done)
+	call.Metadata, _ = metadata.FromOutgoingContext(ctx)
+	call.observe = client.startCall(ctx, call)
+	if err := client.acquire(ctx, block); err != nil {
+		call.Error = err
+		call.done()
+		return call
+	}
 	if err := client.addCall(call); err != nil {
+		client.release(1)
 		call.Error = err
 		call.done()
 		return call
@@ -195,15 +439,47 @@ func (client *Client) Go(serviceMethod string, argv interface{}, reply interface
 	client.sendCall(call)
 	return call
 }
+
+// Call 同步调用，配置了 WithRetryPolicy 时按照重试策略重试失败的调用
 func (client *Client) Call(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}) error {
-	call := client.Go(serviceMethod, argv, reply, make(chan *Call, 1))
-	//call = <-call.Done
-	call.done()
+	err := client.call(ctx, serviceMethod, argv, reply)
+	for attempt := 1; err != nil && client.retry.retryable(err, attempt); attempt++ {
+		wait := client.retry.backoff(err, attempt)
+		client.log().Debug("client: retry call", "method", serviceMethod, "attempt", attempt, "wait", wait, "err", err)
+		if !sleepContext(ctx, wait) {
+			return err
+		}
+		err = client.call(ctx, serviceMethod, argv, reply)
+	}
+	return err
+}
+
+func (client *Client) call(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}) error {
+	call := client.goContext(ctx, serviceMethod, argv, reply, make(chan *Call, 1), true)
 	select {
 	case <-ctx.Done():
 		_ = client.removeCall(call.Num)
-		return errors.New("rpc client: timeout")
+		err := status.New(status.DeadlineExceeded, "rpc client: timeout")
+		if ctx.Err() == context.Canceled {
+			err = status.New(status.Canceled, "rpc client: call canceled")
+		}
+		call.finish(err)
+		return err
 	case call = <-call.Done:
+		setTrailer(ctx, call.Trailer)
 		return call.Error
 	}
 }
+
+type trailerKey struct{}
+
+// WithTrailer 同步调用结束后，将服务端返回的元数据写入 trailer
+func WithTrailer(ctx context.Context, trailer *metadata.MD) context.Context {
+	return context.WithValue(ctx, trailerKey{}, trailer)
+}
+
+func setTrailer(ctx context.Context, md metadata.MD) {
+	if trailer, ok := ctx.Value(trailerKey{}).(*metadata.MD); ok {
+		*trailer = md
+	}
+}
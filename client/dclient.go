@@ -1 +1,103 @@
 package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// DClient 支持服务发现的客户端，根据负载均衡策略选择服务端，
+// 并为每个服务端地址复用一个 Client
+type DClient struct {
+	d       Discovery
+	model   Model
+	mu      sync.Mutex
+	clients map[string]*Client
+	opts    []Option // 建立连接时使用的配置
+}
+
+func NewDClient(d Discovery, model Model, opts ...Option) *DClient {
+	return &DClient{
+		d:       d,
+		model:   model,
+		clients: make(map[string]*Client),
+		opts:    opts,
+	}
+}
+
+// ParseAddr 服务端地址格式为 network@addr，省略 network 时默认为 tcp，例如
+// tcp@127.0.0.1:9999、unix@/tmp/tinyrpc.sock、http@127.0.0.1:9999、ws@127.0.0.1:9999、inproc@name，
+// 后三种的连接方式见 DialAddr
+func ParseAddr(rpcAddr string) (network string, addr string) {
+	if i := strings.Index(rpcAddr, "@"); i >= 0 {
+		return rpcAddr[:i], rpcAddr[i+1:]
+	}
+	return "tcp", rpcAddr
+}
+
+func (dc *DClient) dial(rpcAddr string) (*Client, error) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	c, ok := dc.clients[rpcAddr]
+	if ok && !c.IsAvailable() {
+		_ = c.Close()
+		delete(dc.clients, rpcAddr)
+		c = nil
+	}
+	if c == nil {
+		var err error
+		if c, err = DialAddr(rpcAddr, 0, dc.opts...); err != nil {
+			return nil, err
+		}
+		if ok {
+			c.metrics.reconnects.Add(1)
+		}
+		dc.clients[rpcAddr] = c
+	}
+	return c, nil
+}
+
+func (dc *DClient) Call(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}) error {
+	rpcAddr, err := dc.d.Get(dc.model)
+	if err != nil {
+		return err
+	}
+	c, err := dc.dial(rpcAddr)
+	if err != nil {
+		return err
+	}
+	return c.Call(ctx, serviceMethod, argv, reply)
+}
+
+// Go 选择服务端后异步调用，选择或连接失败时返回的 Call 直接带有错误
+func (dc *DClient) Go(serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
+	return dc.GoContext(context.Background(), serviceMethod, argv, reply, done)
+}
+
+func (dc *DClient) GoContext(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
+	rpcAddr, err := dc.d.Get(dc.model)
+	var c *Client
+	if err == nil {
+		c, err = dc.dial(rpcAddr)
+	}
+	if err != nil {
+		if done == nil || cap(done) == 0 {
+			done = make(chan *Call, 1)
+		}
+		call := NewCall(serviceMethod, argv, reply, done)
+		call.Error = err
+		call.done()
+		return call
+	}
+	return c.GoContext(ctx, serviceMethod, argv, reply, done)
+}
+
+func (dc *DClient) Close() error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	for rpcAddr, c := range dc.clients {
+		_ = c.Close()
+		delete(dc.clients, rpcAddr)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+)
+
+// AsyncCaller 异步调用的接口，Client 和 DClient 都实现了该接口
+type AsyncCaller interface {
+	Go(serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call
+}
+
+// Invoke 带类型的同步调用，返回值类型在编译期确定：
+//
+//	reply, err := client.Invoke[*Argv, Reply](ctx, c, "TestAdd.Add", &Argv{A: 1, B: 2})
+func Invoke[Req, Resp any](ctx context.Context, c Caller, serviceMethod string, req Req) (Resp, error) {
+	var resp Resp
+	err := c.Call(ctx, serviceMethod, req, &resp)
+	return resp, err
+}
+
+// Future 带类型的异步调用结果
+type Future[Resp any] struct {
+	call  *Call
+	reply *Resp
+	done  chan struct{}
+}
+
+// Async 带类型的异步调用，通过返回的 Future 等待结果
+func Async[Req, Resp any](c AsyncCaller, serviceMethod string, req Req) *Future[Resp] {
+	f := &Future[Resp]{reply: new(Resp), done: make(chan struct{})}
+	call := c.Go(serviceMethod, req, f.reply, make(chan *Call, 1))
+	go func() {
+		f.call = <-call.Done
+		close(f.done)
+	}()
+	return f
+}
+
+// Done 调用结束时关闭，可以在 select 中与其他 channel 一起等待
+func (f *Future[Resp]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待调用结束或 ctx 结束，可以重复调用
+func (f *Future[Resp]) Wait(ctx context.Context) (Resp, error) {
+	var zero Resp
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-f.done:
+		if f.call.Error != nil {
+			return zero, f.call.Error
+		}
+		return *f.reply, nil
+	}
+}
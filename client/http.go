@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"tinyrpc/codec"
+	"tinyrpc/inprocess"
+)
+
+// DialHTTP 通过 HTTP CONNECT 连接挂载在 codec.DefaultRPCPath 上的服务端
+func DialHTTP(network string, addr string, opts ...Option) (*Client, error) {
+	return DialHTTPPath(network, addr, codec.DefaultRPCPath, 0, opts...)
+}
+
+// DialHTTPPath 通过 HTTP CONNECT 连接挂载在 path 上的服务端，timeout 为 0 时不限制
+func DialHTTPPath(network string, addr string, path string, timeout time.Duration, opts ...Option) (*Client, error) {
+	options := applyOptions(opts)
+	conn, err := dialConn(network, addr, timeout, options)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+	}
+	_, _ = io.WriteString(conn, "CONNECT "+path+" HTTP/1.0\n\n")
+	// 服务端在收到握手参数前不会写入其他数据，bufio 不会多读
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err == nil && resp.Status != codec.Connected {
+		err = errors.New("client error: unexpected HTTP response: " + resp.Status)
+	}
+	if err != nil {
+		options.log().Warn("client error: http connect", "addr", addr, "err", err)
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return newClientConn(conn, options)
+}
+
+// DialAddr 连接 network@addr 格式的地址，network 为 http 时通过 HTTP CONNECT 连接 tcp 地址，
+// 为 ws 或 wss 时通过 WebSocket 连接，addr 不含路径时使用 codec.DefaultWebSocketPath，
+// 为 inproc 时连接进程内的服务端
+func DialAddr(rpcAddr string, timeout time.Duration, opts ...Option) (*Client, error) {
+	network, addr := ParseAddr(rpcAddr)
+	switch strings.ToLower(network) {
+	case "http":
+		return DialHTTPPath("tcp", addr, codec.DefaultRPCPath, timeout, opts...)
+	case "ws", "wss":
+		if !strings.Contains(addr, "/") {
+			addr += codec.DefaultWebSocketPath
+		}
+		return DialWebSocket(strings.ToLower(network)+"://"+addr, timeout, opts...)
+	case inprocess.Network:
+		return DialInProcess(addr, timeout, opts...)
+	}
+	return DialTimeout(network, addr, timeout, opts...)
+}
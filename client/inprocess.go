@@ -0,0 +1,18 @@
+package client
+
+import (
+	"time"
+	"tinyrpc/inprocess"
+)
+
+// DialInProcess 连接通过 server.ServeInProcess(name) 提供服务的服务端，
+// 连接基于 net.Pipe，不占用端口，适合单元测试和同一进程内的组件。timeout 为 0 时不限制
+func DialInProcess(name string, timeout time.Duration, opts ...Option) (*Client, error) {
+	options := applyOptions(opts)
+	conn, err := inprocess.Dial(name, timeout)
+	if err != nil {
+		options.log().Warn("client error: dial in-process", "name", name, "err", err)
+		return nil, err
+	}
+	return newClientConn(conn, options)
+}
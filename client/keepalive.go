@@ -0,0 +1,58 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+	"tinyrpc/codec"
+	"tinyrpc/status"
+)
+
+// keepalive 定期发送 ping 并检查上一个 ping 是否收到了 pong，连接关闭后退出
+func (client *Client) keepalive() {
+	ticker := time.NewTicker(client.keepaliveInterval)
+	defer ticker.Stop()
+	waiting := false
+	missed := 0
+	for range ticker.C {
+		if !client.IsAvailable() {
+			return
+		}
+		if atomic.SwapInt32(&client.pong, 0) == 1 {
+			missed = 0
+		} else if waiting {
+			missed++
+		}
+		if missed >= client.keepaliveMissed {
+			client.log().Warn("client error: keepalive timeout", "peer", client.remoteAddr, "missed", missed)
+			client.fail(status.New(status.Unavailable, "client error: keepalive timeout"))
+			return
+		}
+		// 半开的连接上写入可能阻塞，在单独的协程中发送，上一个 ping 还没写完时不再发送
+		if atomic.CompareAndSwapInt32(&client.pinging, 0, 1) {
+			go client.sendPing()
+		}
+		waiting = true
+	}
+}
+
+func (client *Client) sendPing() {
+	defer atomic.StoreInt32(&client.pinging, 0)
+	client.send.Lock()
+	defer client.send.Unlock()
+	if err := client.codecc.WriteHeader(codec.Header{ServiceMethod: codec.PingMethod}); err != nil {
+		return
+	}
+	_ = client.codecc.WriteBody("ping")
+}
+
+// fail 以 err 为原因关闭连接，等待中的调用都返回 err
+func (client *Client) fail(err error) {
+	client.clientMux.Lock()
+	if client.closeErr == nil {
+		client.closeErr = err
+	}
+	client.clientMux.Unlock()
+	// 先关闭连接，让阻塞的写入返回并释放发送锁
+	_ = client.codecc.Close()
+	client.broadcastCall(err)
+}
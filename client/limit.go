@@ -0,0 +1,18 @@
+package client
+
+import (
+	"tinyrpc/status"
+)
+
+// limitError 读取时超过了响应大小限制则转换为 ResourceExhausted 错误，其他错误原样返回
+func (client *Client) limitError(err error) error {
+	if err == nil || !client.limited.Exceeded() {
+		return err
+	}
+	return status.Errorf(status.ResourceExhausted, "client error: response larger than %d bytes", client.maxResponseSize)
+}
+
+// requestTooLarge 请求超过 maxRequestSize 时返回的错误
+func (client *Client) requestTooLarge() error {
+	return status.Errorf(status.ResourceExhausted, "client error: request larger than %d bytes", client.maxRequestSize)
+}
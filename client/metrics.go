@@ -0,0 +1,61 @@
+package client
+
+import (
+	"strings"
+	"time"
+	"tinyrpc/metrics"
+	"tinyrpc/status"
+)
+
+// clientMetrics 客户端上报的指标
+type clientMetrics struct {
+	requests    metrics.Counter
+	latency     metrics.Histogram
+	pending     metrics.Gauge
+	connections metrics.Gauge
+	reconnects  metrics.Counter
+	bytesIn     metrics.Counter
+	bytesOut    metrics.Counter
+	reqSize     metrics.Histogram
+	respSize    metrics.Histogram
+}
+
+func newClientMetrics(p metrics.Provider) *clientMetrics {
+	if p == nil {
+		p = metrics.Discard
+	}
+	return &clientMetrics{
+		requests:    p.NewCounter("tinyrpc_client_requests_total", "Calls completed by the client.", "service", "method", "code"),
+		latency:     p.NewHistogram("tinyrpc_client_request_duration_seconds", "Time from sending a call to receiving its reply.", nil, "service", "method"),
+		pending:     p.NewGauge("tinyrpc_client_pending_calls", "Calls waiting for a reply."),
+		connections: p.NewGauge("tinyrpc_client_connections", "Open connections to servers."),
+		reconnects:  p.NewCounter("tinyrpc_client_reconnects_total", "Connections re-established after the previous one was lost."),
+		bytesIn:     p.NewCounter("tinyrpc_client_received_bytes_total", "Bytes read from server connections."),
+		bytesOut:    p.NewCounter("tinyrpc_client_sent_bytes_total", "Bytes written to server connections."),
+		reqSize:     p.NewHistogram("tinyrpc_client_request_size_bytes", "Size of requests written to servers, including the header.", metrics.SizeBuckets, "service", "method"),
+		respSize:    p.NewHistogram("tinyrpc_client_response_size_bytes", "Size of responses read from servers, including the header.", metrics.SizeBuckets, "service", "method"),
+	}
+}
+
+// begin 记录调用开始，返回调用结束时调用的函数
+func (m *clientMetrics) begin(serviceMethod string) func(err error) {
+	start := time.Now()
+	service, method := splitServiceMethod(serviceMethod)
+	return func(err error) {
+		m.requests.Add(1, service, method, status.CodeOf(err).String())
+		m.latency.Observe(time.Since(start).Seconds(), service, method)
+	}
+}
+
+// observeSize 记录一条消息的字节数
+func (m *clientMetrics) observeSize(h metrics.Histogram, serviceMethod string, size int64) {
+	service, method := splitServiceMethod(serviceMethod)
+	h.Observe(float64(size), service, method)
+}
+
+func splitServiceMethod(serviceMethod string) (service string, method string) {
+	if dot := strings.LastIndex(serviceMethod, "."); dot >= 0 {
+		return serviceMethod[:dot], serviceMethod[dot+1:]
+	}
+	return serviceMethod, ""
+}
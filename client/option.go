@@ -0,0 +1,86 @@
+package client
+
+import (
+	"crypto/tls"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/codec"
+	"tinyrpc/logging"
+	"tinyrpc/metrics"
+	"tinyrpc/trace"
+)
+
+// Option 用于在建立连接时配置 Client
+type Option func(client *Client)
+
+// WithCodec 指定与服务端协商使用的编解码方式，默认为 gob
+func WithCodec(codecType codec.Type) Option {
+	return func(client *Client) {
+		client.conArgs.CodecType = codecType
+	}
+}
+
+// WithCredentials 在握手时提交凭证，Dial 会等待服务端的认证结果，失败时返回 Unauthenticated 错误
+func WithCredentials(credentials auth.Credentials) Option {
+	return func(client *Client) {
+		client.credentials = credentials
+	}
+}
+
+// WithTLSConfig 通过 TLS 建立连接，config 未设置 ServerName 时使用拨号地址中的主机名。
+// 需要 mTLS 时设置 Certificates 或 GetClientCertificate
+func WithTLSConfig(config *tls.Config) Option {
+	return func(client *Client) {
+		client.tlsConfig = config
+	}
+}
+
+// WithMetrics 上报调用次数、耗时、等待回复的调用数、连接数和流量等指标，例如 metrics.Default
+func WithMetrics(provider metrics.Provider) Option {
+	return func(client *Client) {
+		client.provider = provider
+	}
+}
+
+// WithTracer 为每次调用创建 client span，并通过请求元数据把 span 上下文传给服务端
+func WithTracer(tracer *trace.Tracer) Option {
+	return func(client *Client) {
+		client.tracer = tracer
+	}
+}
+
+// WithLogger 指定输出日志的 Logger，默认使用 logging.Default()
+func WithLogger(logger logging.Logger) Option {
+	return func(client *Client) {
+		client.logger = logger
+	}
+}
+
+// WithKeepalive 每隔 interval 发送一次 ping，连续 maxMissed 次没有收到 pong 时认为连接已经失效，
+// 关闭连接并让等待中的调用返回 Unavailable 错误。maxMissed 小于 1 时为 1。
+// interval 需要不小于服务端允许的最小 ping 间隔，见 server.WithMinPingInterval
+func WithKeepalive(interval time.Duration, maxMissed int) Option {
+	return func(client *Client) {
+		if maxMissed < 1 {
+			maxMissed = 1
+		}
+		client.keepaliveInterval = interval
+		client.keepaliveMissed = maxMissed
+	}
+}
+
+// WithMaxRequestSize 发送的请求（header 和 body）的最大字节数，超过时不发送该请求并返回 ResourceExhausted 错误，
+// 连接可以继续使用。默认不限制
+func WithMaxRequestSize(n int) Option {
+	return func(client *Client) {
+		client.maxRequestSize = int64(n)
+	}
+}
+
+// WithMaxResponseSize 收到的响应（header 和 body）的最大字节数，超过时该调用返回 ResourceExhausted 错误，
+// 由于无法跳过剩余的数据，连接会被关闭。默认不限制
+func WithMaxResponseSize(n int) Option {
+	return func(client *Client) {
+		client.maxResponseSize = int64(n)
+	}
+}
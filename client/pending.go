@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"tinyrpc/status"
+)
+
+// WithMaxPendingCalls 最多同时等待回复的调用数。达到上限后 Call 等待其他调用结束，直到 ctx 结束；
+// Go 和 GoContext 不等待，返回的 Call 直接带有 ResourceExhausted 错误。默认不限制
+func WithMaxPendingCalls(n int) Option {
+	return func(client *Client) {
+		client.maxPending = n
+	}
+}
+
+// PendingCalls 已经发送、等待回复的调用数，可以用于在多个连接之间均衡负载
+func (client *Client) PendingCalls() int {
+	client.clientMux.Lock()
+	defer client.clientMux.Unlock()
+	return len(client.callQueue)
+}
+
+// acquire 为调用占用一个名额，block 为 false 时名额用尽直接返回错误。
+// 名额在调用离开 callQueue 时由 release 归还
+func (client *Client) acquire(ctx context.Context, block bool) error {
+	if client.slots == nil {
+		return nil
+	}
+	select {
+	case client.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if !block {
+		return status.New(status.ResourceExhausted, "client error: too many pending calls")
+	}
+	select {
+	case client.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		if ctx.Err() == context.Canceled {
+			return status.New(status.Canceled, "client error: call canceled while waiting for pending calls")
+		}
+		return status.New(status.DeadlineExceeded, "client error: timeout waiting for pending calls")
+	}
+}
+
+func (client *Client) release(n int) {
+	if client.slots == nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		<-client.slots
+	}
+}
@@ -0,0 +1,389 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"tinyrpc/health"
+	"tinyrpc/logging"
+	"tinyrpc/status"
+)
+
+const (
+	defaultPoolMin          = 1
+	defaultPoolMax          = 8
+	defaultGrowThreshold    = 32
+	defaultIdleTimeout      = time.Minute
+	defaultHealthInterval   = 10 * time.Second
+	defaultHealthTimeout    = time.Second
+	defaultPoolDialTimeout  = 5 * time.Second
+	defaultMaintainInterval = 10 * time.Second
+	minimumMaintainInterval = 10 * time.Millisecond
+)
+
+var errPoolClosed = errors.New("client error: pool is closed")
+
+// PoolOption 用于配置 Pool
+type PoolOption func(pool *Pool)
+
+// WithPoolSize 连接数的范围，创建时建立 min 个连接，最多 max 个，默认为 1 和 8
+func WithPoolSize(min int, max int) PoolOption {
+	return func(pool *Pool) {
+		pool.min = min
+		pool.max = max
+	}
+}
+
+// WithGrowThreshold 等待回复最少的连接上也有 n 个调用时在后台新建连接，默认为 32
+func WithGrowThreshold(n int) PoolOption {
+	return func(pool *Pool) {
+		pool.growThreshold = n
+	}
+}
+
+// WithIdleTimeout 超过 timeout 没有调用的连接会被关闭，但连接数不会低于 min，默认为 1 分钟
+func WithIdleTimeout(timeout time.Duration) PoolOption {
+	return func(pool *Pool) {
+		pool.idleTimeout = timeout
+	}
+}
+
+// WithHealthCheck 每隔 interval 对每个连接调用 health.CheckMethod，超时或服务端不是 SERVING 时关闭该连接并重新建立，
+// interval 为 0 时不检查。默认每 10 秒检查一次，超时为 1 秒
+func WithHealthCheck(interval time.Duration, timeout time.Duration) PoolOption {
+	return func(pool *Pool) {
+		pool.healthInterval = interval
+		pool.healthTimeout = timeout
+	}
+}
+
+// WithDialOptions 建立连接时使用的配置
+func WithDialOptions(opts ...Option) PoolOption {
+	return func(pool *Pool) {
+		pool.opts = opts
+	}
+}
+
+// pooledConn 连接池中的一个连接
+type pooledConn struct {
+	client   *Client
+	lastUsed time.Time
+}
+
+// Pool 与同一个服务端保持多个连接，每次调用选择等待回复最少的连接，
+// 避免单个连接的发送锁成为瓶颈。连接数在 min 和 max 之间随负载增减
+type Pool struct {
+	rpcAddr        string
+	opts           []Option
+	logger         logging.Logger // 由 opts 得到，只解析一次
+	min            int
+	max            int
+	growThreshold  int
+	idleTimeout    time.Duration
+	healthInterval time.Duration
+	healthTimeout  time.Duration
+
+	mu      sync.Mutex
+	conns   []*pooledConn
+	dialing int // 正在建立的连接数，计入 max
+	closed  bool
+	dialMu  sync.Mutex // 没有可用连接时只由一个调用方同步建立连接
+	done    chan struct{}
+}
+
+// NewPool 创建连接 rpcAddr 的连接池，地址格式见 ParseAddr。建立 min 个连接失败时返回错误
+func NewPool(rpcAddr string, opts ...PoolOption) (*Pool, error) {
+	pool := &Pool{
+		rpcAddr:        rpcAddr,
+		min:            defaultPoolMin,
+		max:            defaultPoolMax,
+		growThreshold:  defaultGrowThreshold,
+		idleTimeout:    defaultIdleTimeout,
+		healthInterval: defaultHealthInterval,
+		healthTimeout:  defaultHealthTimeout,
+		done:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(pool)
+	}
+	pool.logger = applyOptions(pool.opts).log()
+	if pool.min < 0 || pool.max < 1 || pool.min > pool.max {
+		return nil, errors.New("client error: invalid pool size")
+	}
+	for i := 0; i < pool.min; i++ {
+		c, err := pool.dial()
+		if err != nil {
+			_ = pool.Close()
+			return nil, err
+		}
+		pool.add(c)
+	}
+	go pool.maintain()
+	return pool, nil
+}
+
+func (pool *Pool) dial() (*Client, error) {
+	return DialAddr(pool.rpcAddr, defaultPoolDialTimeout, pool.opts...)
+}
+
+// add 将新建立的连接放入池中，池已关闭时关闭该连接
+func (pool *Pool) add(c *Client) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.closed {
+		_ = c.Close()
+		return false
+	}
+	pool.conns = append(pool.conns, &pooledConn{client: c, lastUsed: time.Now()})
+	return true
+}
+
+// leastPending 移除已经断开的连接，返回等待回复最少的连接，调用时需要持有 mu
+func (pool *Pool) leastPending() (*pooledConn, int) {
+	var best *pooledConn
+	bestPending := 0
+	alive := pool.conns[:0]
+	for _, pc := range pool.conns {
+		if !pc.client.IsAvailable() {
+			_ = pc.client.Close()
+			continue
+		}
+		alive = append(alive, pc)
+		if pending := pc.client.PendingCalls(); best == nil || pending < bestPending {
+			best, bestPending = pc, pending
+		}
+	}
+	for i := len(alive); i < len(pool.conns); i++ {
+		pool.conns[i] = nil
+	}
+	pool.conns = alive
+	return best, bestPending
+}
+
+// get 选择一个连接，负载较高时在后台扩容，没有可用连接时同步建立，等待的时间受 ctx 限制
+func (pool *Pool) get(ctx context.Context) (*Client, error) {
+	if c, ok, err := pool.pick(); ok || err != nil {
+		return c, err
+	}
+	pool.dialMu.Lock()
+	defer pool.dialMu.Unlock()
+	// 等待期间其他调用方可能已经建立了连接
+	if c, ok, err := pool.pick(); ok || err != nil {
+		return c, err
+	}
+	type dialResult struct {
+		client *Client
+		err    error
+	}
+	result := make(chan dialResult, 1)
+	go func() {
+		c, err := pool.dial()
+		result <- dialResult{client: c, err: err}
+	}()
+	select {
+	case r := <-result:
+		if r.err != nil {
+			return nil, r.err
+		}
+		if !pool.add(r.client) {
+			return nil, errPoolClosed
+		}
+		return r.client, nil
+	case <-ctx.Done():
+		// 连接建立后仍然放入池中，供之后的调用使用
+		go func() {
+			if r := <-result; r.err == nil {
+				pool.add(r.client)
+			}
+		}()
+		if ctx.Err() == context.Canceled {
+			return nil, status.New(status.Canceled, "client error: call canceled while dialing")
+		}
+		return nil, status.New(status.DeadlineExceeded, "client error: dial timeout")
+	}
+}
+
+func (pool *Pool) pick() (*Client, bool, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.closed {
+		return nil, false, errPoolClosed
+	}
+	pc, pending := pool.leastPending()
+	if pc == nil {
+		return nil, false, nil
+	}
+	if pending >= pool.growThreshold && len(pool.conns)+pool.dialing < pool.max {
+		pool.dialing++
+		go pool.grow()
+	}
+	pc.lastUsed = time.Now()
+	return pc.client, true, nil
+}
+
+// grow 建立一个连接放入池中，调用前需要将 dialing 加一
+func (pool *Pool) grow() error {
+	c, err := pool.dial()
+	pool.mu.Lock()
+	pool.dialing--
+	pool.mu.Unlock()
+	if err != nil {
+		pool.logger.Warn("client error: pool dial", "addr", pool.rpcAddr, "err", err)
+		return err
+	}
+	pool.add(c)
+	return nil
+}
+
+// refill 连接被关闭后重新建立连接，使连接数恢复到 min
+func (pool *Pool) refill() {
+	for {
+		pool.mu.Lock()
+		pool.leastPending()
+		if pool.closed || len(pool.conns)+pool.dialing >= pool.min {
+			pool.mu.Unlock()
+			return
+		}
+		pool.dialing++
+		pool.mu.Unlock()
+		if pool.grow() != nil {
+			// 建立失败时等待下一次维护再重试
+			return
+		}
+	}
+}
+
+// maintain 定期关闭空闲连接并进行健康检查，之后将连接数补充到 min
+func (pool *Pool) maintain() {
+	interval := defaultMaintainInterval
+	if pool.healthInterval > 0 && pool.healthInterval < interval {
+		interval = pool.healthInterval
+	}
+	if pool.idleTimeout > 0 && pool.idleTimeout/2 < interval {
+		interval = pool.idleTimeout / 2
+	}
+	if interval < minimumMaintainInterval {
+		interval = minimumMaintainInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastCheck := time.Now()
+	for {
+		select {
+		case <-pool.done:
+			return
+		case <-ticker.C:
+		}
+		pool.evictIdle()
+		if pool.healthInterval > 0 && time.Since(lastCheck) >= pool.healthInterval {
+			pool.healthCheck()
+			lastCheck = time.Now()
+		}
+		pool.refill()
+	}
+}
+
+// evictIdle 关闭超过 idleTimeout 没有使用的连接，保留至少 min 个
+func (pool *Pool) evictIdle() {
+	if pool.idleTimeout <= 0 {
+		return
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.leastPending()
+	kept := pool.conns[:0]
+	excess := len(pool.conns) - pool.min
+	for _, pc := range pool.conns {
+		if pc.client.PendingCalls() > 0 {
+			// 还有调用在等待回复，从现在开始计算空闲时间
+			pc.lastUsed = time.Now()
+		} else if excess > 0 && time.Since(pc.lastUsed) >= pool.idleTimeout {
+			_ = pc.client.Close()
+			excess--
+			continue
+		}
+		kept = append(kept, pc)
+	}
+	for i := len(kept); i < len(pool.conns); i++ {
+		pool.conns[i] = nil
+	}
+	pool.conns = kept
+}
+
+// healthCheck 检查失败的连接会被关闭，下次选择连接时移除
+func (pool *Pool) healthCheck() {
+	pool.mu.Lock()
+	clients := make([]*Client, 0, len(pool.conns))
+	for _, pc := range pool.conns {
+		clients = append(clients, pc.client)
+	}
+	pool.mu.Unlock()
+	for _, c := range clients {
+		ctx, cancel := context.WithTimeout(context.Background(), pool.healthTimeout)
+		var resp health.CheckResponse
+		err := c.Call(ctx, health.CheckMethod, &health.CheckRequest{}, &resp)
+		cancel()
+		if err == nil && resp.Status != health.StatusServing {
+			err = errors.New("client error: server is " + resp.Status.String())
+		}
+		if err != nil {
+			c.log().Warn("client error: pool health check", "addr", pool.rpcAddr, "err", err)
+			_ = c.Close()
+		}
+	}
+	pool.mu.Lock()
+	pool.leastPending()
+	pool.mu.Unlock()
+}
+
+// Len 返回池中可用的连接数
+func (pool *Pool) Len() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.leastPending()
+	return len(pool.conns)
+}
+
+func (pool *Pool) Call(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}) error {
+	c, err := pool.get(ctx)
+	if err != nil {
+		return err
+	}
+	return c.Call(ctx, serviceMethod, argv, reply)
+}
+
+// Go 选择连接后异步调用，没有可用连接时返回的 Call 直接带有错误
+func (pool *Pool) Go(serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
+	return pool.GoContext(context.Background(), serviceMethod, argv, reply, done)
+}
+
+func (pool *Pool) GoContext(ctx context.Context, serviceMethod string, argv interface{}, reply interface{}, done chan *Call) *Call {
+	c, err := pool.get(ctx)
+	if err != nil {
+		if done == nil || cap(done) == 0 {
+			done = make(chan *Call, 1)
+		}
+		call := NewCall(serviceMethod, argv, reply, done)
+		call.Error = err
+		call.done()
+		return call
+	}
+	return c.GoContext(ctx, serviceMethod, argv, reply, done)
+}
+
+// Close 关闭所有连接，之后的调用都返回错误
+func (pool *Pool) Close() error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.closed {
+		return nil
+	}
+	pool.closed = true
+	close(pool.done)
+	for _, pc := range pool.conns {
+		_ = pc.client.Close()
+	}
+	pool.conns = nil
+	return nil
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"time"
+	"tinyrpc/status"
+)
+
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 5 * time.Second
+)
+
+// RetryPolicy 同步调用失败后的重试策略。等待时间从 Backoff 开始每次翻倍，
+// 不超过 MaxBackoff；服务端通过 status.RetryAfterOf 建议了更长的等待时间时以服务端为准
+type RetryPolicy struct {
+	MaxAttempts int           // 包括第一次在内最多的调用次数，小于 2 时不重试
+	Backoff     time.Duration // 第一次重试前的等待时间，默认为 100ms
+	MaxBackoff  time.Duration // 等待时间的上限，默认为 5s
+	Codes       []status.Code // 可以重试的错误码，默认为 Unavailable 和 RateLimited
+}
+
+// WithRetryPolicy 指定 Call 的重试策略，Go 和 GoContext 不会重试。
+// 只应该对重复执行没有副作用的方法，或服务端确认没有执行的错误码重试
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(client *Client) {
+		client.retry = policy
+	}
+}
+
+// retryable 第 attempt 次调用失败后是否可以重试
+func (p *RetryPolicy) retryable(err error, attempt int) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	code := status.CodeOf(err)
+	if len(p.Codes) == 0 {
+		return code == status.Unavailable || code == status.RateLimited
+	}
+	for _, c := range p.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 第 attempt 次调用失败后需要等待的时间
+func (p *RetryPolicy) backoff(err error, attempt int) time.Duration {
+	wait, max := p.Backoff, p.MaxBackoff
+	if wait <= 0 {
+		wait = defaultRetryBackoff
+	}
+	if max <= 0 {
+		max = defaultRetryMaxBackoff
+	}
+	for i := 1; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	if hint := status.RetryAfterOf(err); hint > wait {
+		wait = hint
+	}
+	return wait
+}
+
+// sleepContext 等待 d，ctx 在此之前结束或截止时间早于等待结束时返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
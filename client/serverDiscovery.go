@@ -4,6 +4,8 @@ import (
 	"errors"
 	"hash/crc32"
 	"math/rand"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -70,3 +72,122 @@ func (sd *ServerDiscovery) Get(model Model) (string, error) {
 		return "", errors.New("client discovery: not supported model")
 	}
 }
+func (sd *ServerDiscovery) GetAll() ([]string, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	servers := make([]string, len(sd.servers))
+	copy(servers, sd.servers)
+	return servers, nil
+}
+
+// Refresh 手动维护的服务列表无需刷新
+func (sd *ServerDiscovery) Refresh() error {
+	return nil
+}
+
+// RegistryDiscovery 从注册中心获取服务列表，可以配置多个注册中心地址，
+// 某个注册中心不可用时依次尝试下一个
+type RegistryDiscovery struct {
+	*ServerDiscovery
+	registries []string
+	current    int           // 上一次成功的注册中心
+	timeout    time.Duration // 服务列表的过期时间
+	lastUpdate time.Time
+	refreshing bool // 正在拉取服务列表
+	httpClient *http.Client
+}
+
+const (
+	defaultUpdateTimeout = 10 * time.Second
+	// fetchTimeout 请求单个注册中心的超时时间，不可用的注册中心不会长时间阻塞调用方
+	fetchTimeout = time.Second
+)
+
+func NewRegistryDiscovery(registries []string, timeout time.Duration) *RegistryDiscovery {
+	if timeout == 0 {
+		timeout = defaultUpdateTimeout
+	}
+	return &RegistryDiscovery{
+		ServerDiscovery: NewServerDiscovery(make([]string, 0), ""),
+		registries:      registries,
+		timeout:         timeout,
+		httpClient:      &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+func (rd *RegistryDiscovery) Update(servers []string) error {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	rd.servers = servers
+	rd.lastUpdate = time.Now()
+	return nil
+}
+
+// Refresh 服务列表过期后重新从注册中心拉取。拉取时不持有锁，
+// 其他调用方在拉取期间继续使用旧的服务列表，没有旧列表时各自拉取
+func (rd *RegistryDiscovery) Refresh() error {
+	rd.mu.Lock()
+	if rd.lastUpdate.Add(rd.timeout).After(time.Now()) || (rd.refreshing && len(rd.servers) > 0) {
+		rd.mu.Unlock()
+		return nil
+	}
+	if len(rd.registries) == 0 {
+		rd.mu.Unlock()
+		return errors.New("client discovery error: no registry configured")
+	}
+	rd.refreshing = true
+	current := rd.current
+	rd.mu.Unlock()
+
+	var err error
+	for i := 0; i < len(rd.registries); i++ {
+		index := (current + i) % len(rd.registries)
+		var servers []string
+		if servers, err = rd.fetch(rd.registries[index]); err != nil {
+			continue
+		}
+		rd.mu.Lock()
+		rd.current = index
+		rd.servers = servers
+		rd.lastUpdate = time.Now()
+		rd.refreshing = false
+		rd.mu.Unlock()
+		return nil
+	}
+	rd.mu.Lock()
+	rd.refreshing = false
+	rd.mu.Unlock()
+	return errors.New("client discovery error: all registries unavailable: " + err.Error())
+}
+
+func (rd *RegistryDiscovery) fetch(registry string) ([]string, error) {
+	resp, err := rd.httpClient.Get(registry)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("registry " + registry + " " + resp.Status)
+	}
+	servers := make([]string, 0)
+	for _, server := range strings.Split(resp.Header.Get("servers"), ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+func (rd *RegistryDiscovery) Get(model Model) (string, error) {
+	if err := rd.Refresh(); err != nil {
+		return "", err
+	}
+	return rd.ServerDiscovery.Get(model)
+}
+
+func (rd *RegistryDiscovery) GetAll() ([]string, error) {
+	if err := rd.Refresh(); err != nil {
+		return nil, err
+	}
+	return rd.ServerDiscovery.GetAll()
+}
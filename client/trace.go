@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"tinyrpc/metadata"
+	"tinyrpc/trace"
+)
+
+// startCall 开始记录调用的指标和 client span，并把 span 上下文放入请求元数据，
+// 返回调用结束时调用的函数
+func (client *Client) startCall(ctx context.Context, call *Call) func(err error) {
+	observe := client.metrics.begin(call.ServerMethod)
+	if client.tracer == nil {
+		return observe
+	}
+	_, span := client.tracer.Start(ctx, call.ServerMethod, trace.KindClient)
+	span.SetAttribute("rpc.method", call.ServerMethod)
+	span.SetAttribute("net.peer.address", client.remoteAddr)
+	call.Metadata = metadata.Join(call.Metadata, metadata.Pairs(trace.MetadataKey, span.SpanContext().Traceparent()))
+	return func(err error) {
+		observe(err)
+		span.End(err)
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+	"tinyrpc/codec"
+	"tinyrpc/websocket"
+)
+
+// DialWebSocket 通过 WebSocket 连接服务端，rawURL 形如 ws://127.0.0.1:9999/_tinyrpc_/ws，
+// wss 时通过 TLS 连接，可以用 WithTLSConfig 指定证书。默认使用 json 编解码，timeout 为 0 时不限制
+func DialWebSocket(rawURL string, timeout time.Duration, opts ...Option) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	options := applyOptions(append([]Option{WithCodec(codec.JsonType)}, opts...))
+	switch u.Scheme {
+	case "ws":
+	case "wss":
+		if options.tlsConfig == nil {
+			options.tlsConfig = &tls.Config{}
+		}
+	default:
+		return nil, errors.New("client error: unsupported websocket scheme " + u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := dialConn("tcp", addr, timeout, options)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+	}
+	wsConn, err := websocket.Client(conn, u.Host, u.RequestURI())
+	if err != nil {
+		options.log().Warn("client error: websocket handshake", "url", rawURL, "err", err)
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return newClientConn(wsConn, options)
+}
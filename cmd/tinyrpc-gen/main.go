@@ -0,0 +1,299 @@
+/*
+tinyrpc-gen 根据服务类型生成带类型的客户端和服务端接口，配合 go generate 使用：
+
+	//go:generate go run tinyrpc/cmd/tinyrpc-gen -type TestAdd
+
+对类型 T 生成：
+  - TServer 接口，包含 T 所有可以被注册的方法，并检查 T 实现了该接口
+  - TClient 以及 NewTClient(c client.Caller)，每个方法形如
+    Add(ctx context.Context, argv *Argv) (*Reply, error)
+
+方法名和参数类型不一致会在编译期报错，而不是运行时的 can't find method。
+*/
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "tinyrpc-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("tinyrpc-gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	typeNames := fs.String("type", "", "comma-separated list of service type names; must be set")
+	dir := fs.String("dir", ".", "directory of the package containing the service types")
+	output := fs.String("output", "", "output file name; default <type>_rpc.go")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *typeNames == "" {
+		fs.Usage()
+		return errors.New("-type must be set")
+	}
+	names := strings.Split(*typeNames, ",")
+	if *output == "" {
+		*output = strings.ToLower(names[0]) + "_rpc.go"
+	}
+	// 输出为测试文件时，服务类型也可以定义在测试文件中
+	withTests := strings.HasSuffix(*output, "_test.go")
+	src, err := generate(*dir, names, withTests)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(*dir, *output), src, 0644)
+}
+
+// method 一个可以被注册的服务方法
+type method struct {
+	name        string
+	argv        string // 参数类型表达式
+	reply       string // 返回值类型表达式，不含指针
+	withContext bool   // 服务端方法的第一个参数为 context.Context
+}
+
+type service struct {
+	name    string
+	methods []method
+}
+
+type generator struct {
+	fset    *token.FileSet
+	pkg     string
+	imports map[string]string // 生成代码需要的 import 路径 -> 包名
+}
+
+func generate(dir string, names []string, withTests bool) ([]byte, error) {
+	g := &generator{fset: token.NewFileSet(), imports: make(map[string]string)}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || (!withTests && strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+		f, err := parser.ParseFile(g.fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		// 同一个目录下可能同时存在 xxx 和 xxx_test 两个包，忽略外部测试包
+		if strings.HasSuffix(f.Name.Name, "_test") {
+			continue
+		}
+		g.pkg = f.Name.Name
+		files = append(files, f)
+	}
+	if g.pkg == "" {
+		return nil, errors.New("no Go files in " + dir)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return g.fset.File(files[i].Pos()).Name() < g.fset.File(files[j].Pos()).Name()
+	})
+
+	var services []*service
+	for _, name := range names {
+		s, err := g.findService(files, name)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, s)
+	}
+	return g.render(services)
+}
+
+// findService 收集类型 name 上所有形如 ([ctx context.Context,] argv T, reply *R) error 的导出方法，
+// 与 server.NewService 的规则一致
+func (g *generator) findService(files []*ast.File, name string) (*service, error) {
+	s := &service{name: name}
+	found := false
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == name {
+						found = true
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil || receiverName(d.Recv.List[0].Type) != name || !d.Name.IsExported() {
+					continue
+				}
+				if m, ok := g.serviceMethod(f, d); ok {
+					s.methods = append(s.methods, m)
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, errors.New("type " + name + " not found")
+	}
+	if len(s.methods) == 0 {
+		return nil, errors.New("type " + name + " has no service methods")
+	}
+	sort.Slice(s.methods, func(i, j int) bool {
+		return s.methods[i].name < s.methods[j].name
+	})
+	return s, nil
+}
+
+func receiverName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+func (g *generator) serviceMethod(f *ast.File, d *ast.FuncDecl) (method, bool) {
+	var params []ast.Expr
+	for _, field := range d.Type.Params.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			params = append(params, field.Type)
+		}
+	}
+	withContext := len(params) == 3 && isContext(f, params[0])
+	if withContext {
+		params = params[1:]
+	}
+	results := d.Type.Results
+	if len(params) != 2 || results == nil || len(results.List) != 1 || len(results.List[0].Names) > 1 {
+		return method{}, false
+	}
+	if ident, ok := results.List[0].Type.(*ast.Ident); !ok || ident.Name != "error" {
+		return method{}, false
+	}
+	reply, ok := params[1].(*ast.StarExpr)
+	if !ok {
+		return method{}, false
+	}
+	g.addImports(f, params[0])
+	g.addImports(f, reply.X)
+	return method{
+		name:        d.Name.Name,
+		argv:        types.ExprString(params[0]),
+		reply:       types.ExprString(reply.X),
+		withContext: withContext,
+	}, true
+}
+
+// isContext 判断参数类型是否为 context.Context
+func isContext(f *ast.File, expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Context" {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	for _, spec := range f.Imports {
+		if spec.Path.Value != `"context"` {
+			continue
+		}
+		return (spec.Name == nil && pkg.Name == "context") || (spec.Name != nil && spec.Name.Name == pkg.Name)
+	}
+	return false
+}
+
+// addImports 记录类型表达式中引用的其他包
+func (g *generator) addImports(f *ast.File, expr ast.Expr) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			name := filepath.Base(path)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			if name == pkg.Name {
+				g.imports[path] = name
+			}
+		}
+		return false
+	})
+}
+
+func (g *generator) render(services []*service) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by tinyrpc-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", g.pkg)
+	g.imports["context"] = "context"
+	g.imports["tinyrpc/client"] = "client"
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	fmt.Fprintf(&buf, "import (\n")
+	for _, path := range paths {
+		if name := g.imports[path]; name != filepath.Base(path) {
+			fmt.Fprintf(&buf, "\t%s %q\n", name, path)
+		} else {
+			fmt.Fprintf(&buf, "\t%q\n", path)
+		}
+	}
+	fmt.Fprintf(&buf, ")\n")
+
+	for _, s := range services {
+		fmt.Fprintf(&buf, "\n// %sServer 服务端需要实现的方法\n", s.name)
+		fmt.Fprintf(&buf, "type %sServer interface {\n", s.name)
+		for _, m := range s.methods {
+			if m.withContext {
+				fmt.Fprintf(&buf, "\t%s(ctx context.Context, argv %s, reply *%s) error\n", m.name, m.argv, m.reply)
+			} else {
+				fmt.Fprintf(&buf, "\t%s(argv %s, reply *%s) error\n", m.name, m.argv, m.reply)
+			}
+		}
+		fmt.Fprintf(&buf, "}\n\n")
+		fmt.Fprintf(&buf, "var _ %sServer = (*%s)(nil)\n\n", s.name, s.name)
+
+		fmt.Fprintf(&buf, "// %sClient 调用 %s 服务的客户端\n", s.name, s.name)
+		fmt.Fprintf(&buf, "type %sClient struct {\n\tc client.Caller\n}\n\n", s.name)
+		fmt.Fprintf(&buf, "func New%sClient(c client.Caller) *%sClient {\n", s.name, s.name)
+		fmt.Fprintf(&buf, "\treturn &%sClient{c: c}\n}\n", s.name)
+		for _, m := range s.methods {
+			fmt.Fprintf(&buf, "\nfunc (c *%sClient) %s(ctx context.Context, argv %s) (*%s, error) {\n", s.name, m.name, m.argv, m.reply)
+			fmt.Fprintf(&buf, "\treply := new(%s)\n", m.reply)
+			fmt.Fprintf(&buf, "\tif err := c.c.Call(ctx, %q, argv, reply); err != nil {\n", s.name+"."+m.name)
+			fmt.Fprintf(&buf, "\t\treturn nil, err\n\t}\n")
+			fmt.Fprintf(&buf, "\treturn reply, nil\n}\n")
+		}
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const serviceSrc = `package calc
+
+import (
+	"context"
+	"errors"
+	pb "example.com/proto"
+)
+
+type Calc struct{}
+
+func (c *Calc) Add(argv *pb.Pair, reply *int) error { return nil }
+func (c Calc) Neg(n int, reply *int) error          { return errors.New("x") }
+func (c *Calc) helper(a, b *int) error              { return nil }
+func (c *Calc) Wrong(a int, b int) error            { return nil }
+func (c *Calc) NoError(a int, b *int)               {}
+func (c *Calc) Sum(ctx context.Context, n []int, reply *int) error { return nil }
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "calc.go"), []byte(serviceSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-dir", dir, "-type", "Calc"}, os.Stderr); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(filepath.Join(dir, "calc_rpc.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(out)
+	for _, want := range []string{
+		"package calc",
+		`pb "example.com/proto"`,
+		"Add(argv *pb.Pair, reply *int) error",
+		"Neg(argv int, reply *int) error",
+		"Sum(ctx context.Context, argv []int, reply *int) error",
+		"func (c *CalcClient) Sum(ctx context.Context, argv []int) (*int, error)",
+		"var _ CalcServer = (*Calc)(nil)",
+		"func NewCalcClient(c client.Caller) *CalcClient",
+		"func (c *CalcClient) Add(ctx context.Context, argv *pb.Pair) (*int, error)",
+		`c.c.Call(ctx, "Calc.Neg", argv, reply)`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Fatalf("generated code does not contain %q:\n%s", want, src)
+		}
+	}
+	for _, unwanted := range []string{"helper", "Wrong", "NoError", `"errors"`} {
+		if strings.Contains(src, unwanted) {
+			t.Fatalf("generated code contains %q:\n%s", unwanted, src)
+		}
+	}
+
+	if err := run([]string{"-dir", dir, "-type", "Missing"}, os.Stderr); err == nil {
+		t.Fatal("missing type should fail")
+	}
+}
@@ -0,0 +1,306 @@
+/*
+tinyrpc 命令行工具，用于调试已经部署的服务
+
+	tinyrpc [-timeout 5s] [-codec json] list <addr> [service]
+	tinyrpc [-timeout 5s] call <addr> Service.Method '{"A":1,"B":2}'
+	tinyrpc [-timeout 5s] [-codec json] health <addr> [service]
+	tinyrpc [-timeout 5s] registry ls <registry-url>...
+
+addr 的格式为 network@addr，省略 network 时默认为 tcp，http@host:port 通过 HTTP CONNECT 连接。
+服务端要求认证时使用 -token 提交 bearer token，使用 TLS 时通过 -tls 或 -ca 开启，
+mTLS 时再用 -cert 和 -key 指定客户端证书
+*/
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/client"
+	"tinyrpc/codec"
+	"tinyrpc/server"
+)
+
+type cli struct {
+	out       io.Writer
+	timeout   time.Duration
+	codecType codec.Type
+	opts      []client.Option // 连接服务端时使用的选项
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "tinyrpc:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("tinyrpc", flag.ContinueOnError)
+	fs.SetOutput(out)
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout of each call")
+	codecType := fs.String("codec", string(codec.JsonType), "codec used to talk to the server: gob or json")
+	token := fs.String("token", "", "bearer token sent when the server requires authentication")
+	useTLS := fs.Bool("tls", false, "connect with TLS using the system root CAs")
+	caFile := fs.String("ca", "", "PEM file of CAs used to verify the server, implies -tls")
+	certFile := fs.String("cert", "", "PEM client certificate for mutual TLS, implies -tls")
+	keyFile := fs.String("key", "", "PEM private key of -cert")
+	serverName := fs.String("servername", "", "server name to verify, defaults to the host in addr")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "usage: tinyrpc [flags] <command> [args]")
+		fmt.Fprintln(out, "commands:")
+		fmt.Fprintln(out, "  list <addr> [service]             list services and methods via reflection")
+		fmt.Fprintln(out, "  call <addr> Service.Method <json> call a method with a JSON argument")
+		fmt.Fprintln(out, "  health <addr> [service]           check the serving status")
+		fmt.Fprintln(out, "  registry ls <registry-url>...     list servers known by a registry")
+		fmt.Fprintln(out, "flags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c := &cli{out: out, timeout: *timeout, codecType: codec.Type(*codecType)}
+	if codec.MakeCodecFuncMap[c.codecType] == nil {
+		return errors.New("unknown codec " + *codecType)
+	}
+	c.opts = append(c.opts, client.WithCodec(c.codecType))
+	if *token != "" {
+		c.opts = append(c.opts, client.WithCredentials(auth.BearerToken(*token)))
+	}
+	if *useTLS || *caFile != "" || *certFile != "" {
+		config, err := tlsConfig(*caFile, *certFile, *keyFile, *serverName)
+		if err != nil {
+			return err
+		}
+		c.opts = append(c.opts, client.WithTLSConfig(config))
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "list":
+		return c.list(rest)
+	case "call":
+		return c.call(rest)
+	case "health":
+		return c.health(rest)
+	case "registry":
+		if len(rest) == 0 || rest[0] != "ls" {
+			return errors.New("usage: registry ls <registry-url>...")
+		}
+		return c.registryList(rest[1:])
+	default:
+		fs.Usage()
+		return errors.New("unknown command " + cmd)
+	}
+}
+
+// tlsConfig 根据命令行参数创建 TLS 配置，caFile 为空时使用系统的根证书
+func tlsConfig(caFile string, certFile string, keyFile string, serverName string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// dial 连接服务端，同一个命令的所有调用共用这个连接
+func (c *cli) dial(rpcAddr string) (*client.Client, error) {
+	return client.DialAddr(rpcAddr, c.timeout, c.opts...)
+}
+
+// invoke 在 rc 上发起调用，每次调用单独计算超时
+func (c *cli) invoke(rc *client.Client, serviceMethod string, argv interface{}, reply interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return rc.Call(ctx, serviceMethod, argv, reply)
+}
+
+func (c *cli) list(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: list <addr> [service]")
+	}
+	rc, err := c.dial(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	services := args[1:]
+	if len(services) == 0 {
+		var resp server.ListServicesResponse
+		if err := c.invoke(rc, "Reflection.ListServices", &server.ListServicesRequest{}, &resp); err != nil {
+			return err
+		}
+		services = resp.Services
+	}
+	for _, service := range services {
+		var desc server.ServiceDescriptor
+		if err := c.invoke(rc, "Reflection.DescribeService", &server.DescribeServiceRequest{Service: service}, &desc); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, desc.Name)
+		for _, m := range desc.Methods {
+			fmt.Fprintf(c.out, "  %s.%s(%s) returns (%s)\n", desc.Name, m.Name, typeString(m.Argv), typeString(m.Reply))
+			if len(args) == 2 {
+				fmt.Fprintf(c.out, "    argv:  %s\n", structString(m.Argv))
+				fmt.Fprintf(c.out, "    reply: %s\n", structString(m.Reply))
+			}
+		}
+	}
+	return nil
+}
+
+// typeString 按 Go 语法输出类型名称
+func typeString(t *server.TypeDescriptor) string {
+	if t == nil {
+		return "?"
+	}
+	if t.Name != "" {
+		return t.Name
+	}
+	switch t.Kind {
+	case "ptr":
+		return "*" + typeString(t.Elem)
+	case "slice":
+		return "[]" + typeString(t.Elem)
+	case "array":
+		return fmt.Sprintf("[%d]%s", t.Len, typeString(t.Elem))
+	case "map":
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Elem)
+	case "struct":
+		return structString(t)
+	default:
+		return t.Kind
+	}
+}
+
+// structString 展开结构体的字段
+func structString(t *server.TypeDescriptor) string {
+	for t != nil && t.Kind == "ptr" {
+		t = t.Elem
+	}
+	if t == nil || t.Kind != "struct" {
+		return typeString(t)
+	}
+	fields := make([]string, 0, len(t.Fields))
+	for _, f := range t.Fields {
+		fields = append(fields, f.Name+" "+typeString(f.Type))
+	}
+	return "struct { " + strings.Join(fields, "; ") + " }"
+}
+
+func (c *cli) call(args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return errors.New("usage: call <addr> Service.Method <json>")
+	}
+	if c.codecType != codec.JsonType {
+		return errors.New("call requires the json codec")
+	}
+	argv := json.RawMessage("null")
+	if len(args) == 3 {
+		argv = json.RawMessage(args[2])
+		if !json.Valid(argv) {
+			return errors.New("argument is not valid JSON")
+		}
+	}
+	rc, err := c.dial(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	var reply json.RawMessage
+	if err := c.invoke(rc, args[1], argv, &reply); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, string(reply))
+	return nil
+}
+
+func (c *cli) health(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: health <addr> [service]")
+	}
+	req := &server.HealthCheckRequest{}
+	if len(args) == 2 {
+		req.Service = args[1]
+	}
+	rc, err := c.dial(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	var resp server.HealthCheckResponse
+	if err := c.invoke(rc, server.HealthCheckMethod, req, &resp); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, resp.Status)
+	if resp.Status != server.StatusServing {
+		return errors.New("server is " + resp.Status.String())
+	}
+	return nil
+}
+
+// registryList 依次尝试每个注册中心，输出第一个可用注册中心返回的服务列表
+func (c *cli) registryList(registries []string) error {
+	if len(registries) == 0 {
+		return errors.New("usage: registry ls <registry-url>...")
+	}
+	httpClient := &http.Client{Timeout: c.timeout}
+	var err error
+	for _, registry := range registries {
+		var resp *http.Response
+		if resp, err = httpClient.Get(registry); err != nil {
+			continue
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = errors.New("registry " + registry + " " + resp.Status)
+			continue
+		}
+		for _, entry := range strings.Split(resp.Header.Get("health"), ",") {
+			if entry == "" {
+				continue
+			}
+			addr, status := entry, ""
+			if i := strings.LastIndex(entry, "="); i >= 0 {
+				addr, status = entry[:i], entry[i+1:]
+			}
+			fmt.Fprintf(c.out, "%s\t%s\n", addr, status)
+		}
+		return nil
+	}
+	return err
+}
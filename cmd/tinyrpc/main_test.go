@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/registry"
+	"tinyrpc/server"
+)
+
+type Argv struct {
+	A int
+	B int
+}
+type Reply struct {
+	C int
+}
+type Calc struct{}
+
+func (c *Calc) Add(argv *Argv, reply *Reply) error {
+	reply.C = argv.A + argv.B
+	return nil
+}
+
+func TestCommands(t *testing.T) {
+	s := server.NewServer()
+	_ = s.Register(&Calc{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+	addr := "tcp@" + lis.Addr().String()
+
+	r := registry.NewRegistry(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	if err := server.SendHeartbeat(ts.URL, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"list", addr}, "Calc.Add(*main.Argv) returns (*main.Reply)"},
+		{[]string{"-codec", "gob", "list", addr, "Calc"}, "argv:  struct { A int; B int }"},
+		{[]string{"call", addr, "Calc.Add", `{"A":1,"B":2}`}, `{"C":3}`},
+		{[]string{"health", addr}, "SERVING"},
+		{[]string{"-timeout", "1s", "registry", "ls", ts.URL}, addr + "\tpassing"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := run(tt.args, &out); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Fatalf("%v: output %q does not contain %q", tt.args, out.String(), tt.want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"call", addr, "Calc.Sub", `{}`}, &out); err == nil {
+		t.Fatal("call of unknown method should fail")
+	}
+	if err := run([]string{"-codec", "gob", "call", addr, "Calc.Add", `{}`}, &out); err == nil {
+		t.Fatal("call with gob codec should fail")
+	}
+}
+
+// countingListener 统计接受的连接数
+type countingListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&l.accepted, 1)
+	}
+	return conn, err
+}
+
+// writeSelfSigned 生成 127.0.0.1 的自签名证书，证书同时作为 CA 使用，返回证书和私钥文件的路径
+func writeSelfSigned(t *testing.T, dir string, name string) (certFile string, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	_ = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestCommandsWithTokenAndTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeSelfSigned(t, dir, "server")
+	clientCert, clientKey := writeSelfSigned(t, dir, "client")
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	pemData, _ := os.ReadFile(clientCert)
+	clientCAs.AppendCertsFromPEM(pemData)
+
+	s := server.NewServer(server.WithAuthenticator(auth.TokenAuthenticator{"secret": {Name: "cli"}}))
+	_ = s.Register(&Calc{})
+	tcp, _ := net.Listen("tcp", "127.0.0.1:0")
+	lis := &countingListener{Listener: tcp}
+	go s.ServeTLS(lis, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	defer func() { _ = lis.Close() }()
+	addr := "tcp@" + tcp.Addr().String()
+	flags := []string{"-timeout", "1s", "-token", "secret", "-ca", serverCert, "-cert", clientCert, "-key", clientKey}
+
+	var out bytes.Buffer
+	for _, args := range [][]string{
+		{"-timeout", "1s", "health", addr},
+		{"-timeout", "1s", "-ca", serverCert, "-cert", clientCert, "-key", clientKey, "health", addr},
+	} {
+		if err := run(args, &out); err == nil {
+			t.Fatalf("%v should fail without credentials", args)
+		}
+	}
+	if err := run(append(flags, "health", addr), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// list 的所有调用共用一个连接
+	before := atomic.LoadInt32(&lis.accepted)
+	out.Reset()
+	if err := run(append(flags, "list", addr), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Calc.Add") || !strings.Contains(out.String(), "Health.Check") {
+		t.Fatalf("list output %q", out.String())
+	}
+	if n := atomic.LoadInt32(&lis.accepted) - before; n != 1 {
+		t.Fatalf("list opened %d connections", n)
+	}
+}
@@ -7,19 +7,51 @@ import "io"
 
 type Type string
 
+const (
+	GobType  Type = "gob"
+	JsonType Type = "json"
+)
+
 // ConArgs 建立连接时互相确认的参数
 type ConArgs struct {
 	Protocol  string
 	CodecType Type
+	// Credentials 客户端提交的认证信息，不为空时服务端会用 Num 为 0 的 header 返回认证结果
+	Credentials map[string]string
 }
 
+// 客户端和服务端共用的 HTTP 传输参数，服务端通过 server.Mount 挂载，客户端通过 client.DialHTTP 连接
+const (
+	// DefaultRPCPath 客户端通过 CONNECT 该路径建立连接
+	DefaultRPCPath = "/_tinyrpc_"
+	// Connected CONNECT 成功时返回的状态
+	Connected = "200 Connected to Tiny RPC"
+	// DefaultWebSocketPath 客户端通过 WebSocket 连接该路径
+	DefaultWebSocketPath = "/_tinyrpc_/ws"
+)
+
 // Header 调用的头部信息
 type Header struct {
 	Num           uint64 //请求序号
 	ServiceMethod string //方法名称
-	Error         error
+	Error         string //服务端返回的错误信息，error 接口无法被 gob 编码
+	Code          uint32 //错误码，见 status 包
+	RetryAfter    int64  //服务端建议的重试等待时间，单位为毫秒，见 status.RetryAfterOf
+	// Metadata 请求中为客户端发送的元数据，响应中为服务端返回的 trailer
+	Metadata map[string]string
 }
 
+// Num 为 0 的连接级消息使用的方法名。客户端定期发送 PingMethod，服务端回复 PongMethod，
+// body 为占位的字符串，用于发现已经失效的连接
+const (
+	PingMethod = "tinyrpc.Ping"
+	PongMethod = "tinyrpc.Pong"
+)
+
+// BatchMethod 批量调用的头部，Num 为 0，body 为之后紧跟的请求数。
+// 这些请求与普通请求相同，由客户端在一次写入中发送，服务端分别回复
+const BatchMethod = "tinyrpc.Batch"
+
 // Codec 用于实现不同编解码器的接口
 type Codec interface {
 	ReadHeader(header *Header) error
@@ -32,11 +64,12 @@ type MakeCodecFunc func(conn io.ReadWriteCloser) Codec
 
 var DefaultConArgs = &ConArgs{
 	Protocol:  "rpc",
-	CodecType: "gob",
+	CodecType: GobType,
 }
 var MakeCodecFuncMap map[Type]MakeCodecFunc
 
 func init() {
 	MakeCodecFuncMap = make(map[Type]MakeCodecFunc)
-	MakeCodecFuncMap["gob"] = MakeGobCodecFunc //map中储存不同数据类型对应的构造函数，可水平拓展
+	MakeCodecFuncMap[GobType] = MakeGobCodecFunc //map中储存不同数据类型对应的构造函数，可水平拓展
+	MakeCodecFuncMap[JsonType] = MakeJsonCodecFunc
 }
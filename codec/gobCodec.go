@@ -3,7 +3,7 @@ package codec
 import (
 	"encoding/gob"
 	"io"
-	"log"
+	"tinyrpc/logging"
 )
 
 type GobCodec struct {
@@ -22,28 +22,28 @@ func MakeGobCodecFunc(conn io.ReadWriteCloser) Codec {
 }
 func (codec *GobCodec) ReadHeader(header *Header) error {
 	if err := codec.dec.Decode(header); err != nil {
-		log.Println("codec error:gob decoding header", err)
+		logging.Default().Debug("codec error: gob decoding header", "err", err)
 		return err
 	}
 	return nil
 }
 func (codec *GobCodec) ReadBody(body interface{}) error {
 	if err := codec.dec.Decode(body); err != nil {
-		log.Println("codec error:gob decoding body", err)
+		logging.Default().Debug("codec error: gob decoding body", "err", err)
 		return err
 	}
 	return nil
 }
 func (codec *GobCodec) WriteHeader(header Header) error {
 	if err := codec.enc.Encode(header); err != nil {
-		log.Println("codec error:gob encoding header", err)
+		logging.Default().Debug("codec error: gob encoding header", "err", err)
 		return err
 	}
 	return nil
 }
 func (codec *GobCodec) WriteBody(body interface{}) error {
 	if err := codec.enc.Encode(body); err != nil {
-		log.Println("codec error:gob encoding body", err)
+		logging.Default().Debug("codec error: gob encoding body", "err", err)
 		return err
 	}
 	return nil
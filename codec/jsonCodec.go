@@ -0,0 +1,60 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+	"tinyrpc/logging"
+)
+
+// JsonCodec header 和 body 分别编码为一个 JSON 值，便于调试和非 Go 客户端调用
+type JsonCodec struct {
+	conn io.ReadWriteCloser
+	dec  *json.Decoder
+	enc  *json.Encoder
+}
+
+func MakeJsonCodecFunc(conn io.ReadWriteCloser) Codec {
+	jsonCodec := JsonCodec{
+		conn: conn,
+		dec:  json.NewDecoder(conn),
+		enc:  json.NewEncoder(conn),
+	}
+	return &jsonCodec
+}
+func (codec *JsonCodec) ReadHeader(header *Header) error {
+	if err := codec.dec.Decode(header); err != nil {
+		logging.Default().Debug("codec error: json decoding header", "err", err)
+		return err
+	}
+	return nil
+}
+
+// ReadBody body 为 nil 时丢弃该值
+func (codec *JsonCodec) ReadBody(body interface{}) error {
+	if body == nil {
+		var discard json.RawMessage
+		body = &discard
+	}
+	if err := codec.dec.Decode(body); err != nil {
+		logging.Default().Debug("codec error: json decoding body", "err", err)
+		return err
+	}
+	return nil
+}
+func (codec *JsonCodec) WriteHeader(header Header) error {
+	if err := codec.enc.Encode(header); err != nil {
+		logging.Default().Debug("codec error: json encoding header", "err", err)
+		return err
+	}
+	return nil
+}
+func (codec *JsonCodec) WriteBody(body interface{}) error {
+	if err := codec.enc.Encode(body); err != nil {
+		logging.Default().Debug("codec error: json encoding body", "err", err)
+		return err
+	}
+	return nil
+}
+func (codec *JsonCodec) Close() error {
+	return codec.conn.Close()
+}
@@ -0,0 +1,272 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// ErrMessageTooLarge 消息超过了大小限制
+var ErrMessageTooLarge = errors.New("codec error: message too large")
+
+// LimitConn 统计读写的字节数，并限制每条消息（header 和 body）从连接中读取的字节数。
+// 每读取一条消息前调用 Reset，超过限制后 Read 返回 ErrMessageTooLarge，不会读入整条消息。
+// 编解码器会预读数据，ReadSize 是近似值；由于没有消息边界，超过限制后连接不能继续使用。
+// 发送时通过 Hold 和 BeginMessage 缓存编码后的消息，超过限制的消息在写入连接前丢弃，连接可以继续使用
+type LimitConn struct {
+	io.ReadWriteCloser
+	codecType Type
+	limit     int64 // 小于等于 0 时不限制
+	remaining int64
+	read      int64
+	exceeded  bool
+	broken    bool // 已经读到了超过限制的 gob 消息长度
+	gob       gobFrames
+	written   int64 // 原子操作，写入的总字节数
+
+	// 以下字段用于发送时缓存消息，只在持有发送锁时访问
+	holding  bool
+	held     []heldChunk
+	msgStart int // 当前消息在 held 中的起始位置
+	msgLimit int64
+	msgSize  int64
+}
+
+func NewLimitConn(conn io.ReadWriteCloser, codecType Type, limit int64) *LimitConn {
+	return &LimitConn{ReadWriteCloser: conn, codecType: codecType, limit: limit, remaining: limit}
+}
+
+// Reset 开始读取下一条消息
+func (c *LimitConn) Reset() {
+	c.remaining = c.limit
+	c.read = 0
+}
+
+// ReadSize 上次 Reset 后读取的字节数
+func (c *LimitConn) ReadSize() int64 {
+	return c.read
+}
+
+// Exceeded 是否有消息超过了限制
+func (c *LimitConn) Exceeded() bool {
+	return c.exceeded
+}
+
+// Written 写入的总字节数，在发送锁内前后两次的差即为一条消息的大小
+func (c *LimitConn) Written() int64 {
+	return atomic.LoadInt64(&c.written)
+}
+
+func (c *LimitConn) Type() Type {
+	return c.codecType
+}
+
+func (c *LimitConn) Read(p []byte) (int, error) {
+	if c.broken || (c.limit > 0 && c.remaining <= 0) {
+		c.exceeded = true
+		return 0, ErrMessageTooLarge
+	}
+	if c.limit > 0 && int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.ReadWriteCloser.Read(p)
+	if c.limit > 0 && c.codecType == GobType {
+		// gob 解码时按照消息声明的长度一次性分配内存，需要在解码器读到长度前拒绝。
+		// 超过限制的消息可能是预读的下一条，之前的数据仍然正常返回，之后的读取都会失败
+		if bad := c.gob.check(p[:n], c.limit); bad >= 0 {
+			c.broken = true
+			n, err = bad, nil
+		}
+	}
+	c.read += int64(n)
+	if c.limit > 0 {
+		c.remaining -= int64(n)
+	}
+	if n == 0 && c.broken {
+		c.exceeded = true
+		return 0, ErrMessageTooLarge
+	}
+	return n, err
+}
+
+func (c *LimitConn) Write(p []byte) (int, error) {
+	if c.holding {
+		c.hold(p)
+		return len(p), nil
+	}
+	n, err := c.ReadWriteCloser.Write(p)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+// gobFrames 跟踪 gob 流中每条消息的长度前缀
+type gobFrames struct {
+	payload int64  // 当前消息还未读取的负载字节数
+	need    int    // 多字节长度前缀还需要的字节数
+	length  uint64 // 正在读取的长度
+}
+
+// check 检查新读取的数据，返回第一个声明的长度超过 limit 的消息在 p 中的起始位置，没有时返回 -1
+func (g *gobFrames) check(p []byte, limit int64) int {
+	start := 0 // 当前长度前缀的起始位置，前缀跨越两次读取时为 0
+	for i := 0; i < len(p); {
+		if g.payload > 0 {
+			n := g.payload
+			if int64(len(p)-i) < n {
+				n = int64(len(p) - i)
+			}
+			g.payload -= n
+			i += int(n)
+			continue
+		}
+		b := p[i]
+		if g.need == 0 {
+			start = i
+		}
+		i++
+		if g.need > 0 {
+			g.length = g.length<<8 | uint64(b)
+			if g.need--; g.need > 0 {
+				continue
+			}
+		} else if b < 0x80 {
+			g.length = uint64(b)
+		} else {
+			// 长度大于 127 时第一个字节为后续字节数的相反数
+			g.need = int(-int8(b))
+			g.length = 0
+			if g.need > 8 {
+				return start
+			}
+			continue
+		}
+		if g.length > uint64(limit) {
+			return start
+		}
+		g.payload = int64(g.length)
+	}
+	return -1
+}
+
+// heldChunk 缓存的一次写入，编解码器每次编码一个值只写入一次
+type heldChunk struct {
+	data    []byte
+	typeDef bool // gob 的类型定义，编码器认为已经发送，丢弃消息时需要保留
+}
+
+// Hold 之后的写入先缓存，直到 Flush 或 FlushEach，只在持有发送锁时使用
+func (c *LimitConn) Hold() {
+	c.holding = true
+	c.BeginMessage(0)
+}
+
+// BeginMessage 开始缓存一条消息（header 和 body），limit 小于等于 0 时不限制大小
+func (c *LimitConn) BeginMessage(limit int64) {
+	c.msgStart = len(c.held)
+	c.msgLimit = limit
+	c.msgSize = 0
+}
+
+// EndMessage 返回 BeginMessage 之后写入的字节数。超过限制时丢弃这条消息并返回 ErrMessageTooLarge，
+// 大小在写入时统计，不需要额外编码一次
+func (c *LimitConn) EndMessage() (int64, error) {
+	size, limit := c.msgSize, c.msgLimit
+	if limit > 0 && size > limit {
+		c.DiscardMessage()
+	}
+	c.BeginMessage(0)
+	if limit > 0 && size > limit {
+		return size, ErrMessageTooLarge
+	}
+	return size, nil
+}
+
+// DiscardMessage 丢弃 BeginMessage 之后缓存的数据，gob 的类型定义仍然会发送，编码器的状态与对端保持一致
+func (c *LimitConn) DiscardMessage() {
+	kept := c.held[:c.msgStart]
+	for _, chunk := range c.held[c.msgStart:] {
+		if chunk.typeDef {
+			kept = append(kept, chunk)
+		}
+	}
+	c.held = kept
+	c.msgStart = len(c.held)
+}
+
+// Discard 丢弃所有缓存的消息，gob 的类型定义仍然保留，之后仍然需要 Flush 发送
+func (c *LimitConn) Discard() {
+	c.msgStart = 0
+	c.DiscardMessage()
+}
+
+// Flush 在一次写入中发送缓存的数据并停止缓存
+func (c *LimitConn) Flush() error {
+	c.holding = false
+	if len(c.held) == 0 {
+		return nil
+	}
+	n := 0
+	for _, chunk := range c.held {
+		n += len(chunk.data)
+	}
+	buf := make([]byte, 0, n)
+	for _, chunk := range c.held {
+		buf = append(buf, chunk.data...)
+	}
+	c.held = c.held[:0]
+	_, err := c.Write(buf)
+	return err
+}
+
+// FlushEach 与 Flush 相同，但每个值单独写入，保持 WebSocket 等传输上每个值一条消息的边界
+func (c *LimitConn) FlushEach() error {
+	c.holding = false
+	held := c.held
+	c.held = c.held[:0]
+	for _, chunk := range held {
+		if _, err := c.Write(chunk.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *LimitConn) hold(p []byte) {
+	c.msgSize += int64(len(p))
+	typeDef := c.codecType == GobType && gobTypeDefinition(p)
+	if !typeDef && c.msgLimit > 0 && c.msgSize > c.msgLimit {
+		// 消息已经超过限制，EndMessage 时会被丢弃，不需要再缓存
+		return
+	}
+	c.held = append(c.held, heldChunk{data: append([]byte(nil), p...), typeDef: typeDef})
+}
+
+// gobTypeDefinition 判断一次写入是否为 gob 的类型定义：长度前缀之后的类型 id 为负数
+func gobTypeDefinition(p []byte) bool {
+	_, n := gobUint(p)
+	if n == 0 {
+		return false
+	}
+	id, m := gobUint(p[n:])
+	// 有符号整数的最低位表示符号
+	return m > 0 && id&1 == 1
+}
+
+// gobUint 解码 gob 的无符号整数，返回值和占用的字节数，数据不完整时字节数为 0
+func gobUint(p []byte) (uint64, int) {
+	if len(p) == 0 {
+		return 0, 0
+	}
+	if p[0] < 0x80 {
+		return uint64(p[0]), 1
+	}
+	n := int(-int8(p[0]))
+	if n > 8 || len(p) < n+1 {
+		return 0, 0
+	}
+	var x uint64
+	for _, b := range p[1 : n+1] {
+		x = x<<8 | uint64(b)
+	}
+	return x, n + 1
+}
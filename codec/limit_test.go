@@ -0,0 +1,90 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"testing"
+)
+
+type nopCloser struct{ io.ReadWriter }
+
+func (nopCloser) Close() error { return nil }
+
+func TestLimitConnGobDeclaredLength(t *testing.T) {
+	// 长度前缀声明了 1 GiB 的消息，解码器分配内存前就应该被拒绝
+	stream := []byte{0xfc, 0x40, 0x00, 0x00, 0x00}
+	conn := NewLimitConn(nopCloser{bytes.NewBuffer(stream)}, GobType, 1024)
+	var body []byte
+	if err := gob.NewDecoder(conn).Decode(&body); !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected ErrMessageTooLarge, got %v", err)
+	}
+	if !conn.Exceeded() {
+		t.Fatal("Exceeded should report the oversized message")
+	}
+}
+
+func TestLimitConnMessages(t *testing.T) {
+	for _, codecType := range []Type{GobType, JsonType} {
+		var buf bytes.Buffer
+		w := MakeCodecFuncMap[codecType](nopCloser{&buf})
+		_ = w.WriteBody(bytes.Repeat([]byte("a"), 100))
+		_ = w.WriteBody(bytes.Repeat([]byte("b"), 100))
+		_ = w.WriteBody(bytes.Repeat([]byte("c"), 4096))
+
+		conn := NewLimitConn(nopCloser{&buf}, codecType, 1024)
+		r := MakeCodecFuncMap[codecType](conn)
+		for i := 0; i < 2; i++ {
+			conn.Reset()
+			var body []byte
+			if err := r.ReadBody(&body); err != nil || len(body) != 100 {
+				t.Fatalf("%s: message %d: len %d err %v", codecType, i, len(body), err)
+			}
+		}
+		conn.Reset()
+		var body []byte
+		if err := r.ReadBody(&body); !errors.Is(err, ErrMessageTooLarge) {
+			t.Fatalf("%s: expected ErrMessageTooLarge, got %v", codecType, err)
+		}
+	}
+}
+
+func TestLimitConnDropsOversizedMessage(t *testing.T) {
+	for _, codecType := range []Type{GobType, JsonType} {
+		var buf bytes.Buffer
+		conn := NewLimitConn(nopCloser{&buf}, codecType, 0)
+		w := MakeCodecFuncMap[codecType](conn)
+		conn.Hold()
+		// 第一条消息超过限制被丢弃，其中 gob 的类型定义仍然需要发送
+		conn.BeginMessage(1024)
+		_ = w.WriteHeader(Header{ServiceMethod: "Blob.Size", Num: 1})
+		_ = w.WriteBody(make([]byte, 4096))
+		if _, err := conn.EndMessage(); !errors.Is(err, ErrMessageTooLarge) {
+			t.Fatalf("%s: expected ErrMessageTooLarge, got %v", codecType, err)
+		}
+		conn.BeginMessage(1024)
+		_ = w.WriteHeader(Header{ServiceMethod: "Blob.Size", Num: 2})
+		_ = w.WriteBody(make([]byte, 100))
+		size, err := conn.EndMessage()
+		if err != nil || size == 0 {
+			t.Fatalf("%s: size %d err %v", codecType, size, err)
+		}
+		if err := conn.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if conn.Written() != int64(buf.Len()) {
+			t.Fatalf("%s: written %d, buffered %d", codecType, conn.Written(), buf.Len())
+		}
+
+		r := MakeCodecFuncMap[codecType](nopCloser{&buf})
+		var header Header
+		var body []byte
+		if err := r.ReadHeader(&header); err != nil || header.Num != 2 {
+			t.Fatalf("%s: header %+v err %v", codecType, header, err)
+		}
+		if err := r.ReadBody(&body); err != nil || len(body) != 100 {
+			t.Fatalf("%s: body len %d err %v", codecType, len(body), err)
+		}
+	}
+}
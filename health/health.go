@@ -0,0 +1,46 @@
+/*
+health 健康检查协议的类型，服务端的 Health 服务、客户端连接池和注册中心的探测共用，
+客户端不需要依赖 server 包
+*/
+package health
+
+import "time"
+
+// CheckMethod 注册中心等外部组件探测服务端健康状态时调用的方法
+const CheckMethod = "Health.Check"
+
+// ServingStatus 服务的健康状态
+type ServingStatus int
+
+const (
+	StatusUnknown ServingStatus = iota
+	StatusServing
+	StatusNotServing
+)
+
+func (s ServingStatus) String() string {
+	switch s {
+	case StatusServing:
+		return "SERVING"
+	case StatusNotServing:
+		return "NOT_SERVING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// CheckRequest Service 为空时表示整个服务端的状态
+type CheckRequest struct {
+	Service string
+}
+
+type CheckResponse struct {
+	Status ServingStatus
+}
+
+// WatchRequest Health.Watch 的参数，状态与 LastStatus 不同或等待超过 Wait 时返回
+type WatchRequest struct {
+	Service    string
+	LastStatus ServingStatus
+	Wait       time.Duration // 为 0 时由服务端决定，超过服务端处理超时时在超时前返回
+}
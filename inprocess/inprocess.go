@@ -0,0 +1,112 @@
+/*
+inprocess 基于 net.Pipe 实现同一进程内的 listener，服务端和客户端通过名称连接，数据不经过网络，
+不占用端口，适合单元测试和同一进程内的组件
+*/
+package inprocess
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Network 进程内连接的网络名称，地址格式为 inproc@name
+const Network = "inproc"
+
+var (
+	mu        sync.Mutex
+	listeners = make(map[string]*listener)
+)
+
+// addr 进程内连接的地址，即 listener 的名称
+type addr string
+
+func (a addr) Network() string { return Network }
+func (a addr) String() string  { return string(a) }
+
+// conn net.Pipe 返回的地址都是 pipe，替换为 listener 的名称
+type conn struct {
+	net.Conn
+	addr addr
+}
+
+func (c *conn) LocalAddr() net.Addr  { return c.addr }
+func (c *conn) RemoteAddr() net.Addr { return c.addr }
+
+// listener Dial 时创建一对连接，一端交给 Accept
+type listener struct {
+	addr  addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Listen 创建名为 name 的进程内 listener，同名的 listener 关闭前不能重复创建
+func Listen(name string) (net.Listener, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := listeners[name]; ok {
+		return nil, errors.New("inprocess error: listener " + name + " already exists")
+	}
+	lis := &listener{
+		addr:  addr(name),
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	listeners[name] = lis
+	return lis, nil
+}
+
+// Dial 连接名为 name 的进程内 listener，返回客户端一端的连接。
+// listener 存在但没有在 Accept 时会一直等待，timeout 为 0 时不限制等待的时间
+func Dial(name string, timeout time.Duration) (net.Conn, error) {
+	mu.Lock()
+	lis, ok := listeners[name]
+	mu.Unlock()
+	if !ok {
+		return nil, errors.New("inprocess error: no listener named " + name)
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	serverConn, clientConn := net.Pipe()
+	select {
+	case lis.conns <- &conn{Conn: serverConn, addr: lis.addr}:
+		return &conn{Conn: clientConn, addr: lis.addr}, nil
+	case <-lis.done:
+		_ = serverConn.Close()
+		_ = clientConn.Close()
+		return nil, errors.New("inprocess error: listener " + name + " is closed")
+	case <-expired:
+		_ = serverConn.Close()
+		_ = clientConn.Close()
+		return nil, errors.New("inprocess error: dial " + name + " timeout")
+	}
+}
+
+func (lis *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-lis.conns:
+		return c, nil
+	case <-lis.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (lis *listener) Close() error {
+	lis.once.Do(func() {
+		close(lis.done)
+		mu.Lock()
+		delete(listeners, string(lis.addr))
+		mu.Unlock()
+	})
+	return nil
+}
+
+func (lis *listener) Addr() net.Addr {
+	return lis.addr
+}
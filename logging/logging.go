@@ -0,0 +1,141 @@
+/*
+logging client、server、codec 等包输出日志使用的接口。
+Logger 的方法与 log/slog 的 *slog.Logger 一致，args 为交替出现的键和值，
+因此可以直接传入 slog.Default() 或者其他日志库的适配。
+没有配置时使用 Default，默认丢弃所有日志
+*/
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Level 与 slog.Level 的取值一致
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// Discard 丢弃所有日志
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Debug(string, ...interface{}) {}
+func (discard) Info(string, ...interface{})  {}
+func (discard) Warn(string, ...interface{})  {}
+func (discard) Error(string, ...interface{}) {}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = Discard
+)
+
+// Default 返回没有单独配置日志的组件使用的 Logger
+func Default() Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault 替换 Default，传入 nil 时恢复为 Discard
+func SetDefault(logger Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if logger == nil {
+		logger = Discard
+	}
+	defaultLogger = logger
+}
+
+// textLogger 按照 slog.TextHandler 的格式输出 level 及以上的日志
+type textLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// New 创建输出到 w 的 Logger，低于 level 的日志被丢弃，每条日志一行：
+//
+//	time=2006-01-02T15:04:05.000Z07:00 level=INFO msg="server started" addr=127.0.0.1:9999
+func New(w io.Writer, level Level) Logger {
+	return &textLogger{w: w, level: level}
+}
+
+func (l *textLogger) Debug(msg string, args ...interface{}) { l.log(LevelDebug, msg, args) }
+func (l *textLogger) Info(msg string, args ...interface{})  { l.log(LevelInfo, msg, args) }
+func (l *textLogger) Warn(msg string, args ...interface{})  { l.log(LevelWarn, msg, args) }
+func (l *textLogger) Error(msg string, args ...interface{}) { l.log(LevelError, msg, args) }
+
+func (l *textLogger) log(level Level, msg string, args []interface{}) {
+	if level < l.level {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(time.Now().Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteString(" level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(quote(msg))
+	for len(args) > 0 {
+		key, value := "!BADKEY", args[0]
+		if k, ok := args[0].(string); ok && len(args) > 1 {
+			key, value = k, args[1]
+			args = args[2:]
+		} else {
+			args = args[1:]
+		}
+		b.WriteByte(' ')
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(quote(fmt.Sprint(value)))
+	}
+	b.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.w, b.String())
+}
+
+// quote 值中包含空格、引号、等号或不可打印字符时加上引号
+func quote(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r == ' ' || r == '"' || r == '=' || !strconv.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo)
+	logger.Debug("hidden")
+	logger.Info("server started", "addr", "127.0.0.1:9999", "codec", "gob")
+	logger.Error("call failed", "err", errors.New("can't find method"), "dangling")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines %q", lines)
+	}
+	// 去掉时间
+	for i, line := range lines {
+		lines[i] = line[strings.Index(line, " level=")+1:]
+	}
+	if want := `level=INFO msg="server started" addr=127.0.0.1:9999 codec=gob`; lines[0] != want {
+		t.Fatalf("got  %s\nwant %s", lines[0], want)
+	}
+	if want := `level=ERROR msg="call failed" err="can't find method" !BADKEY=dangling`; lines[1] != want {
+		t.Fatalf("got  %s\nwant %s", lines[1], want)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	if Default() != Discard {
+		t.Fatal("default logger should discard")
+	}
+	var buf bytes.Buffer
+	SetDefault(New(&buf, LevelDebug))
+	defer SetDefault(nil)
+	Default().Debug("hello")
+	if !strings.Contains(buf.String(), "level=DEBUG msg=hello") {
+		t.Fatalf("output %q", buf.String())
+	}
+	SetDefault(nil)
+	if Default() != Discard {
+		t.Fatal("SetDefault(nil) should restore Discard")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+metadata 调用时携带的元数据，例如 trace id、认证信息、调用方名称等，
+通过 codec.Header 在客户端和服务端之间传递
+*/
+package metadata
+
+import (
+	"context"
+	"strings"
+)
+
+// MD 元数据，键统一为小写
+type MD map[string]string
+
+// Pairs 由 k1, v1, k2, v2... 创建 MD，参数个数为奇数时忽略最后一个
+func Pairs(kv ...string) MD {
+	md := make(MD, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		md.Set(kv[i], kv[i+1])
+	}
+	return md
+}
+
+func (md MD) Get(key string) string {
+	return md[strings.ToLower(key)]
+}
+
+func (md MD) Set(key string, value string) {
+	md[strings.ToLower(key)] = value
+}
+
+// Copy 返回 md 的副本，md 为 nil 时返回空的 MD
+func (md MD) Copy() MD {
+	out := make(MD, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// Join 合并多个 MD，后面的值覆盖前面的
+func Join(mds ...MD) MD {
+	out := MD{}
+	for _, md := range mds {
+		for k, v := range md {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+type outgoingKey struct{}
+type incomingKey struct{}
+
+// NewOutgoingContext 客户端通过 ctx 附加需要发送的元数据
+func NewOutgoingContext(ctx context.Context, md MD) context.Context {
+	return context.WithValue(ctx, outgoingKey{}, md)
+}
+
+// AppendToOutgoingContext 在 ctx 已有的元数据上追加键值对
+func AppendToOutgoingContext(ctx context.Context, kv ...string) context.Context {
+	md, _ := FromOutgoingContext(ctx)
+	return NewOutgoingContext(ctx, Join(md, Pairs(kv...)))
+}
+
+func FromOutgoingContext(ctx context.Context) (MD, bool) {
+	md, ok := ctx.Value(outgoingKey{}).(MD)
+	return md, ok
+}
+
+// NewIncomingContext 由服务端调用，将收到的元数据放入 handler 的 ctx
+func NewIncomingContext(ctx context.Context, md MD) context.Context {
+	return context.WithValue(ctx, incomingKey{}, md)
+}
+
+// FromIncomingContext handler 和拦截器通过 ctx 读取客户端发送的元数据
+func FromIncomingContext(ctx context.Context) (MD, bool) {
+	md, ok := ctx.Value(incomingKey{}).(MD)
+	return md, ok
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import "net"
+
+// MeterConn 将 conn 上读写的字节数分别累加到 in 和 out
+func MeterConn(conn net.Conn, in Counter, out Counter) net.Conn {
+	return &meteredConn{Conn: conn, in: in, out: out}
+}
+
+type meteredConn struct {
+	net.Conn
+	in  Counter
+	out Counter
+}
+
+func (c *meteredConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.in.Add(float64(n))
+	}
+	return n, err
+}
+
+func (c *meteredConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.out.Add(float64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,46 @@
+/*
+metrics Server 和 Client 上报指标的接口，以及不依赖第三方库的默认实现 Registry，
+Registry 按照 Prometheus 文本格式输出，可以直接被抓取。
+需要接入其他监控系统时实现 Provider 即可
+*/
+package metrics
+
+// Provider 创建指标，同名的指标多次创建时返回同一个
+type Provider interface {
+	NewCounter(name string, help string, labelNames ...string) Counter
+	NewGauge(name string, help string, labelNames ...string) Gauge
+	NewHistogram(name string, help string, buckets []float64, labelNames ...string) Histogram
+}
+
+// Counter 只增不减的计数，labelValues 与创建时的 labelNames 一一对应
+type Counter interface {
+	Add(delta float64, labelValues ...string)
+}
+
+type Gauge interface {
+	Add(delta float64, labelValues ...string)
+	Set(value float64, labelValues ...string)
+}
+
+// Histogram 按照 buckets 统计分布，buckets 为各个桶的上界
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// DefaultBuckets 请求耗时的默认分桶，单位为秒
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SizeBuckets 消息大小的分桶，单位为字节
+var SizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1 << 20, 4 << 20, 16 << 20}
+
+// Discard 丢弃所有指标，没有配置 Provider 时使用
+var Discard Provider = discard{}
+
+type discard struct{}
+
+func (discard) NewCounter(string, string, ...string) Counter                { return discard{} }
+func (discard) NewGauge(string, string, ...string) Gauge                    { return discard{} }
+func (discard) NewHistogram(string, string, []float64, ...string) Histogram { return discard{} }
+func (discard) Add(float64, ...string)                                      {}
+func (discard) Set(float64, ...string)                                      {}
+func (discard) Observe(float64, ...string)                                  {}
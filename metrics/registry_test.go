@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests.", "method", "code")
+	requests.Add(1, "Arith.Add", "OK")
+	requests.Add(2, "Arith.Add", "OK")
+	requests.Add(1, `Odd"Name`, "Unknown")
+	r.NewGauge("in_flight", "In-flight\nrequests.").Set(3)
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "method")
+	latency.Observe(0.05, "Arith.Add")
+	latency.Observe(0.5, "Arith.Add")
+	latency.Observe(5, "Arith.Add")
+	r.NewCounter("unused_total", "Never incremented.")
+	if r.NewCounter("requests_total", "Requests.", "method", "code") != requests {
+		t.Fatal("counter with the same name should be shared")
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# HELP in_flight In-flight\nrequests.
+# TYPE in_flight gauge
+in_flight 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="Arith.Add",le="0.1"} 1
+latency_seconds_bucket{method="Arith.Add",le="1"} 2
+latency_seconds_bucket{method="Arith.Add",le="+Inf"} 3
+latency_seconds_sum{method="Arith.Add"} 5.55
+latency_seconds_count{method="Arith.Add"} 3
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="Arith.Add",code="OK"} 3
+requests_total{method="Odd\"Name",code="Unknown"} 1
+`
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("content type %q", rec.Header().Get("Content-Type"))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering requests_total as a gauge should panic")
+		}
+	}()
+	r.NewGauge("requests_total", "Requests.", "method", "code")
+}
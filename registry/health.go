@@ -0,0 +1,170 @@
+package registry
+
+import (
+	"context"
+	"sync"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/health"
+)
+
+// HealthStatus 注册中心主动探测得到的实例状态
+type HealthStatus string
+
+const (
+	Passing  HealthStatus = "passing"
+	Warning  HealthStatus = "warning"
+	Critical HealthStatus = "critical"
+)
+
+// HealthCheckConfig 主动健康检查的配置，零值字段使用默认值
+type HealthCheckConfig struct {
+	Interval       time.Duration   // 探测间隔
+	Timeout        time.Duration   // 单次探测(建立连接+调用)的超时时间
+	WarningLatency time.Duration   // 响应慢于该值时标记为 warning，为 0 时不检查
+	MaxFailures    int             // 连续失败达到该次数后标记为 critical
+	DialOptions    []client.Option // 连接实例时使用的选项，例如认证凭证、TLS 和编码方式
+}
+
+const (
+	defaultCheckInterval = 5 * time.Second
+	defaultCheckTimeout  = time.Second
+	defaultMaxFailures   = 3
+)
+
+type instanceHealth struct {
+	status   HealthStatus
+	failures int
+	lastErr  string
+}
+
+type healthChecker struct {
+	cfg  HealthCheckConfig
+	done chan struct{}
+	once sync.Once
+}
+
+// EnableHealthCheck 开启主动健康检查：定期通过 tinyrpc 调用每个实例的
+// Health.Check，critical 的实例不会出现在服务发现结果中。
+// 集群模式下每个节点各自探测，检查结果不参与复制。
+func (r *Registry) EnableHealthCheck(cfg HealthCheckConfig) {
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultCheckInterval
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultCheckTimeout
+	}
+	if cfg.MaxFailures == 0 {
+		cfg.MaxFailures = defaultMaxFailures
+	}
+	checker := &healthChecker{cfg: cfg, done: make(chan struct{})}
+	r.mu.Lock()
+	old := r.checker
+	r.checker = checker
+	r.mu.Unlock()
+	if old != nil {
+		old.stop()
+	}
+	go r.runHealthCheck(checker)
+}
+
+func (hc *healthChecker) stop() {
+	hc.once.Do(func() {
+		close(hc.done)
+	})
+}
+
+func (r *Registry) runHealthCheck(hc *healthChecker) {
+	t := time.NewTicker(hc.cfg.Interval)
+	defer t.Stop()
+	for {
+		r.checkAll(hc.cfg)
+		select {
+		case <-hc.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (r *Registry) checkAll(cfg HealthCheckConfig) {
+	var group sync.WaitGroup
+	for _, addr := range r.getActiveServers() {
+		group.Add(1)
+		go func(addr string) {
+			defer group.Done()
+			start := time.Now()
+			status, err := probe(addr, cfg.Timeout, cfg.DialOptions)
+			r.recordProbe(addr, cfg, status, time.Since(start), err)
+		}(addr)
+	}
+	group.Wait()
+}
+
+// probe 通过 tinyrpc 协议调用实例的健康检查方法
+func probe(rpcAddr string, timeout time.Duration, opts []client.Option) (health.ServingStatus, error) {
+	c, err := client.DialAddr(rpcAddr, timeout, opts...)
+	if err != nil {
+		return health.StatusUnknown, err
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var resp health.CheckResponse
+	if err := c.Call(ctx, health.CheckMethod, &health.CheckRequest{}, &resp); err != nil {
+		return health.StatusUnknown, err
+	}
+	return resp.Status, nil
+}
+
+func (r *Registry) recordProbe(addr string, cfg HealthCheckConfig, status health.ServingStatus, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.servers[addr]; !ok {
+		return // 探测期间已经注销
+	}
+	h, ok := r.health[addr]
+	if !ok {
+		h = &instanceHealth{}
+		r.health[addr] = h
+	}
+	switch {
+	case err == nil && status != health.StatusServing:
+		// 实例主动声明不可用，直接标记为 critical
+		h.failures = cfg.MaxFailures
+		h.lastErr = "registry error: instance reports " + status.String()
+		h.status = Critical
+	case err != nil:
+		h.failures++
+		h.lastErr = err.Error()
+		if h.failures >= cfg.MaxFailures {
+			h.status = Critical
+		} else {
+			h.status = Warning
+		}
+	case cfg.WarningLatency > 0 && latency > cfg.WarningLatency:
+		h.failures = 0
+		h.lastErr = "registry error: slow health check " + latency.String()
+		h.status = Warning
+	default:
+		h.failures = 0
+		h.lastErr = ""
+		h.status = Passing
+	}
+}
+
+// Status 返回实例当前的健康状态，尚未探测过的实例视为 passing
+func (r *Registry) Status(addr string) HealthStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.statusLocked(addr)
+}
+
+func (r *Registry) statusLocked(addr string) HealthStatus {
+	if h, ok := r.health[addr]; ok && h.status != "" {
+		return h.status
+	}
+	return Passing
+}
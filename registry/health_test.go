@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/client"
+	"tinyrpc/server"
+)
+
+// slowListener 延迟每次写入，模拟响应缓慢或 RPC 循环卡住的服务端
+type slowListener struct {
+	net.Listener
+	delay time.Duration
+}
+type slowConn struct {
+	net.Conn
+	delay time.Duration
+}
+
+func (l *slowListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &slowConn{Conn: conn, delay: l.delay}, nil
+}
+func (c *slowConn) Write(p []byte) (int, error) {
+	time.Sleep(c.delay)
+	return c.Conn.Write(p)
+}
+
+func startHealthServer(t *testing.T, delay time.Duration, status server.ServingStatus) string {
+	s := server.NewServer()
+	s.Health().SetServingStatus("", status)
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(&slowListener{Listener: lis, delay: delay})
+	t.Cleanup(func() { _ = lis.Close() })
+	return "tcp@" + lis.Addr().String()
+}
+
+func TestHealthCheck(t *testing.T) {
+	serving := startHealthServer(t, 0, server.StatusServing)
+	slow := startHealthServer(t, 40*time.Millisecond, server.StatusServing)
+	notServing := startHealthServer(t, 0, server.StatusNotServing)
+	wedged := startHealthServer(t, time.Minute, server.StatusServing)
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	missing := "tcp@" + lis.Addr().String()
+	_ = lis.Close()
+
+	r := NewRegistry(time.Minute)
+	defer func() { _ = r.Close() }()
+	for _, addr := range []string{serving, slow, notServing, wedged, missing} {
+		r.addServer(addr, time.Now())
+	}
+	r.EnableHealthCheck(HealthCheckConfig{
+		Interval:       20 * time.Millisecond,
+		Timeout:        500 * time.Millisecond,
+		WarningLatency: 30 * time.Millisecond,
+		MaxFailures:    2,
+	})
+
+	want := map[string]HealthStatus{
+		serving:    Passing,
+		slow:       Warning,
+		notServing: Critical,
+		wedged:     Critical,
+		missing:    Critical,
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ok := true
+		for addr, status := range want {
+			if r.Status(addr) != status {
+				ok = false
+			}
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			for addr, status := range want {
+				t.Errorf("%s: %s, want %s", addr, r.Status(addr), status)
+			}
+			t.FailNow()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	servers := strings.Split(resp.Header.Get("servers"), ",")
+	if len(servers) != 2 {
+		t.Fatalf("discovery returned %v, want only passing and warning instances", servers)
+	}
+	for _, addr := range servers {
+		if addr != serving && addr != slow {
+			t.Fatalf("unhealthy instance %s returned by discovery", addr)
+		}
+	}
+}
+
+func TestHealthCheckDialOptions(t *testing.T) {
+	s := server.NewServer(server.WithAuthenticator(auth.TokenAuthenticator{"probe": {Name: "registry"}}))
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+	addr := "tcp@" + lis.Addr().String()
+	// 尚未探测过的实例也视为 passing，需要等到有探测结果
+	probed := func(r *Registry) bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		_, ok := r.health[addr]
+		return ok
+	}
+
+	// 没有凭证的探测被服务端拒绝，实例被标记为 critical
+	want := map[HealthStatus][]client.Option{
+		Critical: nil,
+		Passing:  {client.WithCredentials(auth.BearerToken("probe"))},
+	}
+	for status, opts := range want {
+		r := NewRegistry(time.Minute)
+		r.addServer(addr, time.Now())
+		r.EnableHealthCheck(HealthCheckConfig{Interval: 20 * time.Millisecond, Timeout: 500 * time.Millisecond, MaxFailures: 1, DialOptions: opts})
+		deadline := time.Now().Add(5 * time.Second)
+		for r.Status(addr) != status || !probed(r) {
+			if time.Now().After(deadline) {
+				t.Fatalf("status %s, want %s", r.Status(addr), status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		_ = r.Close()
+	}
+}
@@ -0,0 +1,607 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 一个只为注册中心服务的精简 Raft 实现：选主、日志复制和快照压缩，
+// 节点之间通过 HTTP+JSON 通信，状态只保存在内存中。
+
+type raftState int
+
+const (
+	follower raftState = iota
+	candidate
+	leader
+)
+
+var (
+	errNotLeader      = errors.New("registry error: not leader")
+	errLeadershipLost = errors.New("registry error: leadership lost before commit")
+	errProposeTimeout = errors.New("registry error: propose timeout")
+	errNodeClosed     = errors.New("registry error: raft node closed")
+)
+
+type logEntry struct {
+	Term    uint64
+	Command []byte
+}
+
+type voteArgs struct {
+	Term         uint64
+	CandidateID  string
+	LastLogIndex uint64
+	LastLogTerm  uint64
+}
+type voteReply struct {
+	Term        uint64
+	VoteGranted bool
+}
+
+type appendArgs struct {
+	Term         uint64
+	LeaderID     string
+	PrevLogIndex uint64
+	PrevLogTerm  uint64
+	Entries      []logEntry
+	LeaderCommit uint64
+}
+type appendReply struct {
+	Term          uint64
+	Success       bool
+	ConflictIndex uint64
+}
+
+type snapshotArgs struct {
+	Term      uint64
+	LeaderID  string
+	LastIndex uint64
+	LastTerm  uint64
+	Data      []byte
+}
+type snapshotReply struct {
+	Term uint64
+}
+
+// proposal 记录leader提交但尚未应用的命令
+type proposal struct {
+	term uint64
+	done chan error
+}
+
+// stateMachine 由注册中心实现，raft 只负责把命令按顺序交给它
+type stateMachine interface {
+	apply(cmd []byte)
+	snapshot() []byte
+	restore(data []byte)
+}
+
+type raftNode struct {
+	mu     sync.Mutex
+	id     string
+	peers  map[string]string // 其余节点 id -> 基础URL
+	client *http.Client
+	sm     stateMachine
+
+	state       raftState
+	currentTerm uint64
+	votedFor    string
+	leaderID    string
+
+	log           []logEntry // log[0] 为快照位置的哨兵
+	snapshotIndex uint64
+	snapshotData  []byte
+	commitIndex   uint64
+	lastApplied   uint64
+	nextIndex     map[string]uint64
+	matchIndex    map[string]uint64
+	pending       map[uint64]*proposal
+
+	heartbeatInterval time.Duration
+	electionTimeout   time.Duration
+	electionDeadline  time.Time
+	lastBroadcast     time.Time
+	maxLogEntries     int
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newRaftNode(id string, peers map[string]string, sm stateMachine, heartbeat time.Duration) *raftNode {
+	n := &raftNode{
+		id:                id,
+		peers:             make(map[string]string),
+		client:            &http.Client{Timeout: 10 * heartbeat},
+		sm:                sm,
+		log:               []logEntry{{}},
+		nextIndex:         make(map[string]uint64),
+		matchIndex:        make(map[string]uint64),
+		pending:           make(map[uint64]*proposal),
+		heartbeatInterval: heartbeat,
+		electionTimeout:   10 * heartbeat,
+		maxLogEntries:     1024,
+		done:              make(chan struct{}),
+	}
+	for peerID, addr := range peers {
+		if peerID != id {
+			n.peers[peerID] = addr
+		}
+	}
+	n.resetElectionDeadline()
+	return n
+}
+
+func (n *raftNode) start() {
+	go n.run()
+}
+
+func (n *raftNode) stop() {
+	n.once.Do(func() {
+		close(n.done)
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.failPending(errNodeClosed)
+	})
+}
+
+func (n *raftNode) run() {
+	t := time.NewTicker(n.heartbeatInterval / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-t.C:
+			n.tick()
+		}
+	}
+}
+
+func (n *raftNode) tick() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	if n.state == leader {
+		if now.Sub(n.lastBroadcast) >= n.heartbeatInterval {
+			n.broadcast()
+		}
+		return
+	}
+	if now.After(n.electionDeadline) {
+		n.startElection()
+	}
+}
+
+// 以下方法都需要在持有 n.mu 时调用
+
+func (n *raftNode) lastIndex() uint64 {
+	return n.snapshotIndex + uint64(len(n.log)) - 1
+}
+func (n *raftNode) termAt(index uint64) uint64 {
+	return n.log[index-n.snapshotIndex].Term
+}
+func (n *raftNode) majority() int {
+	return (len(n.peers)+1)/2 + 1
+}
+func (n *raftNode) resetElectionDeadline() {
+	d := n.electionTimeout + time.Duration(rand.Int63n(int64(n.electionTimeout)))
+	n.electionDeadline = time.Now().Add(d)
+}
+
+func (n *raftNode) becomeFollower(term uint64) {
+	if n.state == leader {
+		n.failPending(errLeadershipLost)
+	}
+	n.state = follower
+	if term > n.currentTerm {
+		n.currentTerm = term
+		n.votedFor = ""
+	}
+	n.resetElectionDeadline()
+}
+
+func (n *raftNode) startElection() {
+	n.state = candidate
+	n.currentTerm++
+	n.votedFor = n.id
+	n.leaderID = ""
+	n.resetElectionDeadline()
+
+	term := n.currentTerm
+	args := voteArgs{
+		Term:         term,
+		CandidateID:  n.id,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.termAt(n.lastIndex()),
+	}
+	votes := 1
+	if votes >= n.majority() {
+		n.becomeLeader()
+		return
+	}
+	for _, addr := range n.peers {
+		go func(addr string) {
+			var reply voteReply
+			if err := n.post(addr, "vote", &args, &reply); err != nil {
+				return
+			}
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if reply.Term > n.currentTerm {
+				n.becomeFollower(reply.Term)
+				return
+			}
+			if n.state != candidate || n.currentTerm != term || !reply.VoteGranted {
+				return
+			}
+			votes++
+			if votes >= n.majority() {
+				n.becomeLeader()
+			}
+		}(addr)
+	}
+}
+
+func (n *raftNode) becomeLeader() {
+	n.state = leader
+	n.leaderID = n.id
+	for peerID := range n.peers {
+		n.nextIndex[peerID] = n.lastIndex() + 1
+		n.matchIndex[peerID] = 0
+	}
+	// 追加一条空日志，使之前任期的日志能够尽快提交
+	n.log = append(n.log, logEntry{Term: n.currentTerm})
+	n.advanceCommit()
+	n.broadcast()
+}
+
+func (n *raftNode) broadcast() {
+	n.lastBroadcast = time.Now()
+	for peerID := range n.peers {
+		go n.replicate(peerID)
+	}
+}
+
+func (n *raftNode) replicate(peerID string) {
+	n.mu.Lock()
+	if n.state != leader {
+		n.mu.Unlock()
+		return
+	}
+	term := n.currentTerm
+	addr := n.peers[peerID]
+	next := n.nextIndex[peerID]
+	if next <= n.snapshotIndex {
+		args := snapshotArgs{
+			Term:      term,
+			LeaderID:  n.id,
+			LastIndex: n.snapshotIndex,
+			LastTerm:  n.termAt(n.snapshotIndex),
+			Data:      n.snapshotData,
+		}
+		n.mu.Unlock()
+		var reply snapshotReply
+		if err := n.post(addr, "snapshot", &args, &reply); err != nil {
+			return
+		}
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if reply.Term > n.currentTerm {
+			n.becomeFollower(reply.Term)
+			return
+		}
+		if n.state == leader && n.currentTerm == term && args.LastIndex > n.matchIndex[peerID] {
+			n.matchIndex[peerID] = args.LastIndex
+			n.nextIndex[peerID] = args.LastIndex + 1
+		}
+		return
+	}
+	prev := next - 1
+	args := appendArgs{
+		Term:         term,
+		LeaderID:     n.id,
+		PrevLogIndex: prev,
+		PrevLogTerm:  n.termAt(prev),
+		Entries:      append([]logEntry(nil), n.log[next-n.snapshotIndex:]...),
+		LeaderCommit: n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	var reply appendReply
+	if err := n.post(addr, "append", &args, &reply); err != nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if reply.Term > n.currentTerm {
+		n.becomeFollower(reply.Term)
+		return
+	}
+	if n.state != leader || n.currentTerm != term {
+		return
+	}
+	if reply.Success {
+		match := prev + uint64(len(args.Entries))
+		if match > n.matchIndex[peerID] {
+			n.matchIndex[peerID] = match
+			n.nextIndex[peerID] = match + 1
+		}
+		n.advanceCommit()
+		return
+	}
+	if reply.ConflictIndex > 0 && reply.ConflictIndex < n.nextIndex[peerID] {
+		n.nextIndex[peerID] = reply.ConflictIndex
+	} else if n.nextIndex[peerID] > 1 {
+		n.nextIndex[peerID]--
+	}
+}
+
+// advanceCommit leader 只提交当前任期且已复制到多数节点的日志
+func (n *raftNode) advanceCommit() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if n.termAt(index) != n.currentTerm {
+			break
+		}
+		count := 1
+		for _, match := range n.matchIndex {
+			if match >= index {
+				count++
+			}
+		}
+		if count >= n.majority() {
+			n.commitIndex = index
+			n.applyCommitted()
+			return
+		}
+	}
+}
+
+func (n *raftNode) applyCommitted() {
+	for n.lastApplied < n.commitIndex {
+		n.lastApplied++
+		entry := n.log[n.lastApplied-n.snapshotIndex]
+		if entry.Command != nil {
+			n.sm.apply(entry.Command)
+		}
+		if p, ok := n.pending[n.lastApplied]; ok {
+			delete(n.pending, n.lastApplied)
+			if p.term == entry.Term {
+				p.done <- nil
+			} else {
+				p.done <- errLeadershipLost
+			}
+		}
+	}
+	if len(n.log)-1 > n.maxLogEntries {
+		n.compact()
+	}
+}
+
+// compact 用状态机快照替换已经应用的日志
+func (n *raftNode) compact() {
+	term := n.termAt(n.lastApplied)
+	rest := n.log[n.lastApplied-n.snapshotIndex+1:]
+	n.log = append([]logEntry{{Term: term}}, rest...)
+	n.snapshotIndex = n.lastApplied
+	n.snapshotData = n.sm.snapshot()
+}
+
+func (n *raftNode) failPending(err error) {
+	for index, p := range n.pending {
+		p.done <- err
+		delete(n.pending, index)
+	}
+}
+
+// propose 在 leader 上追加一条命令，并等待它被提交和应用
+func (n *raftNode) propose(cmd []byte, timeout time.Duration) error {
+	n.mu.Lock()
+	if n.state != leader {
+		n.mu.Unlock()
+		return errNotLeader
+	}
+	select {
+	case <-n.done:
+		n.mu.Unlock()
+		return errNodeClosed
+	default:
+	}
+	n.log = append(n.log, logEntry{Term: n.currentTerm, Command: cmd})
+	index := n.lastIndex()
+	p := &proposal{term: n.currentTerm, done: make(chan error, 1)}
+	n.pending[index] = p
+	n.advanceCommit()
+	n.broadcast()
+	n.mu.Unlock()
+
+	select {
+	case err := <-p.done:
+		return err
+	case <-time.After(timeout):
+		n.mu.Lock()
+		delete(n.pending, index)
+		n.mu.Unlock()
+		return errProposeTimeout
+	}
+}
+
+// leader 返回当前已知的 leader 的 id 和地址，自己是 leader 时地址为空
+func (n *raftNode) leader() (id string, addr string, isSelf bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.state == leader {
+		return n.id, "", true
+	}
+	return n.leaderID, n.peers[n.leaderID], false
+}
+
+func (n *raftNode) handleVote(args *voteArgs) *voteReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if args.Term > n.currentTerm {
+		n.becomeFollower(args.Term)
+	}
+	reply := &voteReply{Term: n.currentTerm}
+	if args.Term < n.currentTerm {
+		return reply
+	}
+	lastTerm := n.termAt(n.lastIndex())
+	upToDate := args.LastLogTerm > lastTerm ||
+		(args.LastLogTerm == lastTerm && args.LastLogIndex >= n.lastIndex())
+	if (n.votedFor == "" || n.votedFor == args.CandidateID) && upToDate {
+		n.votedFor = args.CandidateID
+		reply.VoteGranted = true
+		n.resetElectionDeadline()
+	}
+	return reply
+}
+
+func (n *raftNode) handleAppend(args *appendArgs) *appendReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if args.Term < n.currentTerm {
+		return &appendReply{Term: n.currentTerm}
+	}
+	n.becomeFollower(args.Term)
+	n.leaderID = args.LeaderID
+	reply := &appendReply{Term: n.currentTerm}
+
+	prev, entries := args.PrevLogIndex, args.Entries
+	if prev < n.snapshotIndex {
+		// 快照之前的日志都已提交，跳过重叠部分
+		skip := n.snapshotIndex - prev
+		if skip > uint64(len(entries)) {
+			skip = uint64(len(entries))
+		}
+		entries = entries[skip:]
+		prev = n.snapshotIndex
+	} else {
+		if prev > n.lastIndex() {
+			reply.ConflictIndex = n.lastIndex() + 1
+			return reply
+		}
+		if n.termAt(prev) != args.PrevLogTerm {
+			conflictTerm := n.termAt(prev)
+			index := prev
+			for index > n.snapshotIndex+1 && n.termAt(index-1) == conflictTerm {
+				index--
+			}
+			reply.ConflictIndex = index
+			return reply
+		}
+	}
+	for i, entry := range entries {
+		index := prev + 1 + uint64(i)
+		if index <= n.lastIndex() {
+			if n.termAt(index) == entry.Term {
+				continue
+			}
+			n.log = n.log[:index-n.snapshotIndex]
+		}
+		n.log = append(n.log, entries[i:]...)
+		break
+	}
+	reply.Success = true
+	if args.LeaderCommit > n.commitIndex {
+		last := prev + uint64(len(entries))
+		if args.LeaderCommit < last {
+			last = args.LeaderCommit
+		}
+		if last > n.commitIndex {
+			n.commitIndex = last
+			n.applyCommitted()
+		}
+	}
+	return reply
+}
+
+func (n *raftNode) handleSnapshot(args *snapshotArgs) *snapshotReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if args.Term < n.currentTerm {
+		return &snapshotReply{Term: n.currentTerm}
+	}
+	n.becomeFollower(args.Term)
+	n.leaderID = args.LeaderID
+	if args.LastIndex <= n.snapshotIndex || args.LastIndex <= n.lastApplied {
+		return &snapshotReply{Term: n.currentTerm}
+	}
+	if args.LastIndex <= n.lastIndex() && n.termAt(args.LastIndex) == args.LastTerm {
+		rest := n.log[args.LastIndex-n.snapshotIndex+1:]
+		n.log = append([]logEntry{{Term: args.LastTerm}}, rest...)
+	} else {
+		n.log = []logEntry{{Term: args.LastTerm}}
+	}
+	n.snapshotIndex = args.LastIndex
+	n.snapshotData = args.Data
+	n.sm.restore(args.Data)
+	n.lastApplied = args.LastIndex
+	if n.commitIndex < args.LastIndex {
+		n.commitIndex = args.LastIndex
+	}
+	return &snapshotReply{Term: n.currentTerm}
+}
+
+func (n *raftNode) post(addr string, rpc string, args interface{}, reply interface{}) error {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(addr+DefaultRaftPath+rpc, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("registry error: raft rpc " + rpc + " " + resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+// ServeHTTP 处理其他节点发来的 raft 请求
+func (n *raftNode) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	select {
+	case <-n.done:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	var reply interface{}
+	dec := json.NewDecoder(req.Body)
+	switch req.URL.Path[len(DefaultRaftPath):] {
+	case "vote":
+		var args voteArgs
+		if err := dec.Decode(&args); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply = n.handleVote(&args)
+	case "append":
+		var args appendArgs
+		if err := dec.Decode(&args); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply = n.handleAppend(&args)
+	case "snapshot":
+		var args snapshotArgs
+		if err := dec.Decode(&args); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply = n.handleSnapshot(&args)
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reply)
+}
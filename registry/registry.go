@@ -1,55 +1,263 @@
 package registry
 
 import (
+	"encoding/json"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	DefaultPath     = "/registry"
+	DefaultRaftPath = "/registry/raft/"
+)
+
 var DefaultRegistry = NewRegistry(time.Second)
 
 type Registry struct {
 	mu      sync.Mutex
 	timeout time.Duration
 	servers map[string]time.Time
+	node    *raftNode // 集群模式下注册和注销事件通过 raft 复制
+	health  map[string]*instanceHealth
+	checker *healthChecker
+
+	expiring bool // 正在删除过期的实例，避免并发的读取重复提交
+}
+
+// registryCommand 注册中心写入 raft 日志的命令
+type registryCommand struct {
+	Op   string // register / deregister / expire
+	Addr string
+	Time int64 // leader 收到请求的时间，保证各节点状态一致；expire 时为过期的注册时间
 }
 
 func NewRegistry(timeout time.Duration) *Registry {
 	return &Registry{
 		servers: make(map[string]time.Time),
+		health:  make(map[string]*instanceHealth),
 		timeout: timeout,
 	}
 }
-func (r *Registry) addServer(addr string) {
+
+// NewClusterRegistry 创建集群中的一个注册中心节点。
+// peers 为所有节点(包含自身) id -> 基础URL，如 http://127.0.0.1:9999，
+// 每个节点都需要在该地址下通过 Mount 挂载 DefaultPath 和 DefaultRaftPath。
+func NewClusterRegistry(id string, peers map[string]string, timeout time.Duration, heartbeat time.Duration) *Registry {
+	r := NewRegistry(timeout)
+	r.node = newRaftNode(id, peers, r, heartbeat)
+	r.node.start()
+	return r
+}
+
+// Close 停止集群节点和健康检查
+func (r *Registry) Close() error {
+	if r.node != nil {
+		r.node.stop()
+	}
 	r.mu.Lock()
-	defer r.mu.Lock()
+	checker := r.checker
+	r.mu.Unlock()
+	if checker != nil {
+		checker.stop()
+	}
+	return nil
+}
+
+// IsLeader 单机模式下总是 leader
+func (r *Registry) IsLeader() bool {
+	if r.node == nil {
+		return true
+	}
+	_, _, isSelf := r.node.leader()
+	return isSelf
+}
+
+func (r *Registry) addServer(addr string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	r.servers[addr] = time.Now()
+	r.servers[addr] = t
 }
+func (r *Registry) removeServer(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.servers, addr)
+	delete(r.health, addr)
+}
+
+// expireServer 只有实例在过期后没有再次注册时才删除，避免删除期间收到的心跳被覆盖
+func (r *Registry) expireServer(addr string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if last, ok := r.servers[addr]; ok && last.Equal(t) {
+		delete(r.servers, addr)
+		delete(r.health, addr)
+	}
+}
+
+// getActiveServers 读取时只过滤过期的实例，不修改状态，过期的实例由 removeExpired 删除
 func (r *Registry) getActiveServers() []string {
 	r.mu.Lock()
-	defer r.mu.Lock()
+	defer r.mu.Unlock()
 	var servers []string
+	expired := false
+	now := time.Now()
 	for addr, t := range r.servers {
-		if t.Add(r.timeout).After(time.Now()) {
+		if t.Add(r.timeout).After(now) {
 			servers = append(servers, addr)
 		} else {
-			delete(r.servers, addr)
+			expired = true
 		}
 	}
+	if expired && !r.expiring {
+		r.expiring = true
+		go r.removeExpired()
+	}
 	return servers
 }
+
+// removeExpired 删除过期的实例。集群模式下只有 leader 通过 raft 日志删除，
+// 保证所有节点的状态和快照一致，follower 只在读取时过滤
+func (r *Registry) removeExpired() {
+	defer func() {
+		r.mu.Lock()
+		r.expiring = false
+		r.mu.Unlock()
+	}()
+	if !r.IsLeader() {
+		return
+	}
+	r.mu.Lock()
+	var expired []registryCommand
+	now := time.Now()
+	for addr, t := range r.servers {
+		if !t.Add(r.timeout).After(now) {
+			expired = append(expired, registryCommand{Op: "expire", Addr: addr, Time: t.UnixNano()})
+		}
+	}
+	r.mu.Unlock()
+	for _, cmd := range expired {
+		data, _ := json.Marshal(cmd)
+		if r.node == nil {
+			r.apply(data)
+		} else if err := r.node.propose(data, r.node.electionTimeout); err != nil {
+			return
+		}
+	}
+}
+
+// getHealthyServers 过滤掉健康检查结果为 critical 的实例
+func (r *Registry) getHealthyServers() (servers []string, statuses []string) {
+	active := r.getActiveServers()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, addr := range active {
+		status := r.statusLocked(addr)
+		statuses = append(statuses, addr+"="+string(status))
+		if status != Critical {
+			servers = append(servers, addr)
+		}
+	}
+	return
+}
+
+func (r *Registry) apply(cmd []byte) {
+	var c registryCommand
+	if err := json.Unmarshal(cmd, &c); err != nil {
+		return
+	}
+	switch c.Op {
+	case "register":
+		r.addServer(c.Addr, time.Unix(0, c.Time))
+	case "deregister":
+		r.removeServer(c.Addr)
+	case "expire":
+		r.expireServer(c.Addr, time.Unix(0, c.Time))
+	}
+}
+func (r *Registry) snapshot() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	servers := make(map[string]int64, len(r.servers))
+	for addr, t := range r.servers {
+		servers[addr] = t.UnixNano()
+	}
+	data, _ := json.Marshal(servers)
+	return data
+}
+func (r *Registry) restore(data []byte) {
+	var servers map[string]int64
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.servers = make(map[string]time.Time, len(servers))
+	for addr, t := range servers {
+		r.servers[addr] = time.Unix(0, t)
+	}
+}
+
+// update 单机模式直接修改，集群模式下由 leader 提交到 raft 日志，
+// follower 把请求重定向到 leader
+func (r *Registry) update(w http.ResponseWriter, op string, addr string) {
+	if addr == "" {
+		http.Error(w, "registry error: missing server header", http.StatusBadRequest)
+		return
+	}
+	cmd := registryCommand{Op: op, Addr: addr, Time: time.Now().UnixNano()}
+	if r.node == nil {
+		data, _ := json.Marshal(cmd)
+		r.apply(data)
+		return
+	}
+	_, leaderAddr, isSelf := r.node.leader()
+	if !isSelf {
+		if leaderAddr == "" {
+			http.Error(w, "registry error: no leader", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Location", leaderAddr+DefaultPath)
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		return
+	}
+	data, _ := json.Marshal(cmd)
+	if err := r.node.propose(data, r.node.electionTimeout); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
 func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.node != nil && strings.HasPrefix(req.URL.Path, DefaultRaftPath) {
+		r.node.ServeHTTP(w, req)
+		return
+	}
 	method := req.Method
 	if method == "GET" {
-		w.Header().Set("servers", strings.Join(r.getActiveServers(), ","))
+		servers, statuses := r.getHealthyServers()
+		w.Header().Set("servers", strings.Join(servers, ","))
+		w.Header().Set("health", strings.Join(statuses, ","))
 	} else if method == "POST" {
-		addr := req.Header.Get("server")
-		r.addServer(addr)
+		r.update(w, "register", req.Header.Get("server"))
+	} else if method == "DELETE" {
+		r.update(w, "deregister", req.Header.Get("server"))
+	} else {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Mount 将注册中心(以及集群模式下的 raft 接口)挂载到 mux 上
+func (r *Registry) Mount(mux *http.ServeMux) {
+	mux.Handle(DefaultPath, r)
+	if r.node != nil {
+		mux.Handle(DefaultRaftPath, r)
 	}
 }
 
 func HandleHTTP() {
-	http.Handle("/registry", DefaultRegistry)
+	DefaultRegistry.Mount(http.DefaultServeMux)
 }
@@ -0,0 +1,213 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testNode struct {
+	id       string
+	registry *Registry
+	server   *httptest.Server
+}
+
+func startCluster(t *testing.T, size int, timeout time.Duration) []*testNode {
+	nodes := make([]*testNode, size)
+	peers := make(map[string]string)
+	for i := range nodes {
+		id := fmt.Sprintf("node%d", i)
+		mux := http.NewServeMux()
+		nodes[i] = &testNode{id: id, server: httptest.NewUnstartedServer(mux)}
+		peers[id] = "http://" + nodes[i].server.Listener.Addr().String()
+	}
+	for _, n := range nodes {
+		n.registry = NewClusterRegistry(n.id, peers, timeout, 20*time.Millisecond)
+		n.registry.Mount(n.server.Config.Handler.(*http.ServeMux))
+		n.server.Start()
+	}
+	t.Cleanup(func() {
+		for _, n := range nodes {
+			_ = n.registry.Close()
+			n.server.Close()
+		}
+	})
+	return nodes
+}
+
+func waitLeader(t *testing.T, nodes []*testNode) *testNode {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var leaders []*testNode
+		for _, n := range nodes {
+			if n.registry.IsLeader() {
+				leaders = append(leaders, n)
+			}
+		}
+		if len(leaders) == 1 {
+			return leaders[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no leader elected")
+	return nil
+}
+
+func send(t *testing.T, method string, url string, addr string) {
+	req, _ := http.NewRequest(method, url+DefaultPath, nil)
+	req.Header.Set("server", addr)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s %s: %s", method, url, resp.Status)
+	}
+}
+
+func waitServers(t *testing.T, n *testNode, want ...string) {
+	sort.Strings(want)
+	deadline := time.Now().Add(5 * time.Second)
+	var got []string
+	for time.Now().Before(deadline) {
+		got = n.registry.getActiveServers()
+		sort.Strings(got)
+		if strings.Join(got, ",") == strings.Join(want, ",") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s servers %v, want %v", n.id, got, want)
+}
+
+func TestClusterReplication(t *testing.T) {
+	nodes := startCluster(t, 3, time.Minute)
+	leader := waitLeader(t, nodes)
+
+	var followerURL string
+	for _, n := range nodes {
+		if n != leader {
+			followerURL = n.server.URL
+		}
+	}
+	// 发给 follower 的写请求会被重定向到 leader
+	send(t, "POST", followerURL, "tcp@127.0.0.1:1")
+	send(t, "POST", leader.server.URL, "tcp@127.0.0.1:2")
+	for _, n := range nodes {
+		waitServers(t, n, "tcp@127.0.0.1:1", "tcp@127.0.0.1:2")
+	}
+
+	send(t, "DELETE", leader.server.URL, "tcp@127.0.0.1:1")
+	for _, n := range nodes {
+		waitServers(t, n, "tcp@127.0.0.1:2")
+	}
+}
+
+func TestClusterFailover(t *testing.T) {
+	nodes := startCluster(t, 3, time.Minute)
+	leader := waitLeader(t, nodes)
+	send(t, "POST", leader.server.URL, "tcp@127.0.0.1:1")
+
+	_ = leader.registry.Close()
+	leader.server.Close()
+	var rest []*testNode
+	for _, n := range nodes {
+		if n != leader {
+			rest = append(rest, n)
+		}
+	}
+	newLeader := waitLeader(t, rest)
+	send(t, "POST", newLeader.server.URL, "tcp@127.0.0.1:2")
+	for _, n := range rest {
+		waitServers(t, n, "tcp@127.0.0.1:1", "tcp@127.0.0.1:2")
+	}
+}
+
+func TestClusterSnapshot(t *testing.T) {
+	nodes := startCluster(t, 3, time.Minute)
+	for _, n := range nodes {
+		n.registry.node.mu.Lock()
+		n.registry.node.maxLogEntries = 4
+		n.registry.node.mu.Unlock()
+	}
+	leader := waitLeader(t, nodes)
+
+	// 停掉一个 follower，让它落后到快照之前
+	var lagging *testNode
+	for _, n := range nodes {
+		if n != leader {
+			lagging = n
+			break
+		}
+	}
+	lagging.registry.node.mu.Lock()
+	want := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		addr := fmt.Sprintf("tcp@127.0.0.1:%d", i)
+		want = append(want, addr)
+		send(t, "POST", leader.server.URL, addr)
+	}
+	lagging.registry.node.mu.Unlock()
+
+	for _, n := range nodes {
+		waitServers(t, n, want...)
+	}
+	leader.registry.node.mu.Lock()
+	snapshotIndex := leader.registry.node.snapshotIndex
+	leader.registry.node.mu.Unlock()
+	if snapshotIndex == 0 {
+		t.Fatal("leader log was not compacted")
+	}
+}
+
+// registered 返回节点状态中的所有实例，包括已经过期的实例
+func registered(r *Registry) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	servers := make([]string, 0, len(r.servers))
+	for addr := range r.servers {
+		servers = append(servers, addr)
+	}
+	return servers
+}
+
+func TestClusterExpire(t *testing.T) {
+	nodes := startCluster(t, 3, 200*time.Millisecond)
+	leader := waitLeader(t, nodes)
+	send(t, "POST", leader.server.URL, "tcp@127.0.0.1:1")
+	for _, n := range nodes {
+		waitServers(t, n, "tcp@127.0.0.1:1")
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	// follower 读取时只过滤过期的实例，不修改自己的状态
+	for _, n := range nodes {
+		if n == leader {
+			continue
+		}
+		if got := n.registry.getActiveServers(); len(got) != 0 {
+			t.Fatalf("%s returned expired servers %v", n.id, got)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if got := registered(n.registry); len(got) != 1 {
+			t.Fatalf("%s state changed by a read: %v", n.id, got)
+		}
+	}
+
+	// leader 读取时通过 raft 日志删除，所有节点的状态保持一致
+	_ = leader.registry.getActiveServers()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, n := range nodes {
+		for len(registered(n.registry)) != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s still has %v", n.id, registered(n.registry))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
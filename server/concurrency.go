@@ -0,0 +1,153 @@
+package server
+
+import (
+	"sync"
+	"tinyrpc/status"
+)
+
+// DefaultQueueSize 没有通过 WithQueueSize 配置时每一级排队的请求数上限
+const DefaultQueueSize = 128
+
+// task 等待执行的请求，run 和 reject 只会调用其中一个
+type task struct {
+	serviceMethod string
+	run           func()
+	reject        func(err error)
+}
+
+// workerPool 由固定数量的 worker 执行请求，所有 worker 都在忙时请求排队，队列满时拒绝
+type workerPool struct {
+	tasks chan task
+}
+
+func newWorkerPool(workers int, queue int) *workerPool {
+	p := &workerPool{tasks: make(chan task, queue)}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	for t := range p.tasks {
+		t.run()
+	}
+}
+
+func (p *workerPool) submit(t task) {
+	select {
+	case p.tasks <- t:
+	default:
+		t.reject(status.New(status.Unavailable, "server error: server is overloaded"))
+	}
+}
+
+// concurrencyLimit 限制同时放行的请求数，超出的请求按顺序排队，队列满时以 err 拒绝。
+// 放行的请求交给 next，执行结束或被下一级拒绝后放行队列中的下一个请求
+type concurrencyLimit struct {
+	mu       sync.Mutex
+	limit    int
+	running  int
+	queue    []task
+	maxQueue int
+	err      error
+	next     func(t task)
+}
+
+func newConcurrencyLimit(limit int, maxQueue int, err error, next func(t task)) *concurrencyLimit {
+	return &concurrencyLimit{limit: limit, maxQueue: maxQueue, err: err, next: next}
+}
+
+func (l *concurrencyLimit) submit(t task) {
+	l.mu.Lock()
+	if l.running < l.limit {
+		l.running++
+		l.mu.Unlock()
+		l.next(l.wrap(t))
+		return
+	}
+	if len(l.queue) >= l.maxQueue {
+		l.mu.Unlock()
+		t.reject(l.err)
+		return
+	}
+	l.queue = append(l.queue, t)
+	l.mu.Unlock()
+}
+
+func (l *concurrencyLimit) wrap(t task) task {
+	return task{
+		serviceMethod: t.serviceMethod,
+		run: func() {
+			t.run()
+			l.release()
+		},
+		reject: func(err error) {
+			t.reject(err)
+			l.release()
+		},
+	}
+}
+
+func (l *concurrencyLimit) release() {
+	l.mu.Lock()
+	if len(l.queue) == 0 {
+		l.running--
+		l.mu.Unlock()
+		return
+	}
+	t := l.queue[0]
+	l.queue[0] = task{}
+	l.queue = l.queue[1:]
+	l.mu.Unlock()
+	l.next(l.wrap(t))
+}
+
+func (server *Server) queueSize() int {
+	switch {
+	case server.queueLen == 0:
+		return DefaultQueueSize
+	case server.queueLen < 0:
+		return 0
+	}
+	return server.queueLen
+}
+
+// initConcurrency 根据配置创建全局的 worker 池和各方法的并发限制
+func (server *Server) initConcurrency() {
+	if server.maxConcurrent > 0 {
+		server.workers = newWorkerPool(server.maxConcurrent, server.queueSize())
+	}
+	server.methodLimits = make(map[string]*concurrencyLimit, len(server.methodConcurrency))
+	for serviceMethod, n := range server.methodConcurrency {
+		err := status.New(status.ResourceExhausted, "server error: too many concurrent requests for "+serviceMethod)
+		server.methodLimits[serviceMethod] = newConcurrencyLimit(n, server.queueSize(), err, server.execute)
+	}
+}
+
+// newConnLimit 每个连接的并发限制，没有配置时返回 nil
+func (server *Server) newConnLimit() *concurrencyLimit {
+	if server.maxConnConcurrent <= 0 {
+		return nil
+	}
+	err := status.New(status.ResourceExhausted, "server error: too many concurrent requests on connection")
+	return newConcurrencyLimit(server.maxConnConcurrent, server.queueSize(), err, server.dispatch)
+}
+
+// dispatch 经过方法的并发限制后执行请求
+func (server *Server) dispatch(t task) {
+	if l, ok := server.methodLimits[t.serviceMethod]; ok {
+		l.submit(t)
+		return
+	}
+	server.execute(t)
+}
+
+// execute 配置了全局并发限制时交给 worker 池，否则直接启动协程
+func (server *Server) execute(t task) {
+	if server.workers != nil {
+		server.workers.submit(t)
+		return
+	}
+	go t.run()
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"tinyrpc/status"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	var mu sync.Mutex
+	var started []int
+	var finish []func()
+	errFull := errors.New("full")
+	l := newConcurrencyLimit(2, 1, errFull, func(t task) {
+		// 由测试决定何时执行结束
+		finish = append(finish, t.run)
+	})
+	rejected := 0
+	for i := 0; i < 4; i++ {
+		i := i
+		l.submit(task{
+			run: func() {
+				mu.Lock()
+				started = append(started, i)
+				mu.Unlock()
+			},
+			reject: func(err error) {
+				if err != errFull {
+					t.Errorf("unexpected error %v", err)
+				}
+				rejected++
+			},
+		})
+	}
+	if len(finish) != 2 || rejected != 1 {
+		t.Fatalf("expected 2 running and 1 rejected, got %d running and %d rejected", len(finish), rejected)
+	}
+	finish[0]()
+	if len(finish) != 3 {
+		t.Fatal("queued task should start after a running task finishes")
+	}
+	finish[1]()
+	finish[2]()
+	if len(started) != 3 || started[2] != 2 {
+		t.Fatalf("unexpected execution order %v", started)
+	}
+	if l.running != 0 || len(l.queue) != 0 {
+		t.Fatalf("limit should be released, running %d queued %d", l.running, len(l.queue))
+	}
+}
+
+func TestConcurrencyLimitRejectedByNext(t *testing.T) {
+	// 下一级拒绝的请求同样要释放名额
+	errNext := errors.New("next")
+	l := newConcurrencyLimit(1, 0, errors.New("full"), func(t task) {
+		t.reject(errNext)
+	})
+	for i := 0; i < 3; i++ {
+		var got error
+		l.submit(task{run: func() {}, reject: func(err error) { got = err }})
+		if got != errNext {
+			t.Fatalf("expected the error from the next stage, got %v", got)
+		}
+	}
+	if l.running != 0 {
+		t.Fatalf("running %d", l.running)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	started := make(chan struct{}, 2)
+	block := make(chan struct{})
+	var group sync.WaitGroup
+	p := newWorkerPool(1, 1)
+	rejected := make(chan error, 1)
+	group.Add(2)
+	for i := 0; i < 3; i++ {
+		p.submit(task{
+			run: func() {
+				started <- struct{}{}
+				<-block
+				group.Done()
+			},
+			reject: func(err error) { rejected <- err },
+		})
+		if i == 0 {
+			// 等待 worker 取走第一个请求
+			<-started
+		}
+	}
+	if err := <-rejected; status.CodeOf(err) != status.Unavailable {
+		t.Fatalf("expected the pool to reject the overflow with Unavailable, got %v", err)
+	}
+	close(block)
+	group.Wait()
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"html/template"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+	"tinyrpc/codec"
+)
+
+// DefaultDebugPath MountDebug 挂载调试页面的路径
+const DefaultDebugPath = "/debug/tinyrpc"
+
+// connInfo 调试页面展示的连接信息
+type connInfo struct {
+	peer      *Peer
+	codec     codec.Type
+	principal string
+	since     time.Time
+}
+
+// updateConn 修改仍在记录中的连接信息
+func (server *Server) updateConn(conn net.Conn, update func(info *connInfo)) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if info, ok := server.conns[conn]; ok {
+		update(info)
+	}
+}
+
+type debugMethod struct {
+	Name  string
+	Argv  string
+	Reply string
+	MethodStats
+}
+
+type debugService struct {
+	Name    string
+	Methods []debugMethod
+}
+
+type debugConn struct {
+	Peer      string
+	Codec     codec.Type
+	TLS       bool
+	Principal string
+	Since     time.Time
+	Age       time.Duration
+}
+
+const debugText = `<html>
+<head><title>tinyrpc debug</title></head>
+<body>
+<h2>Services</h2>
+{{range .Services}}
+<hr>
+<b>Service {{.Name}}</b>
+<table border="1" cellpadding="4">
+<tr><th align="left">Method</th><th>Argv</th><th>Reply</th><th>Calls</th><th>Errors</th><th>In-flight</th><th>p50</th><th>p90</th><th>p99</th></tr>
+{{range .Methods}}
+<tr><td align="left">{{.Name}}</td><td>{{.Argv}}</td><td>{{.Reply}}</td><td align="right">{{.Calls}}</td><td align="right">{{.Errors}}</td><td align="right">{{.InFlight}}</td><td align="right">{{.P50}}</td><td align="right">{{.P90}}</td><td align="right">{{.P99}}</td></tr>
+{{end}}
+</table>
+{{end}}
+<h2>Connections ({{len .Conns}})</h2>
+<table border="1" cellpadding="4">
+<tr><th align="left">Peer</th><th>Codec</th><th>TLS</th><th>Principal</th><th>Since</th></tr>
+{{range .Conns}}
+<tr><td align="left">{{.Peer}}</td><td>{{.Codec}}</td><td>{{.TLS}}</td><td>{{.Principal}}</td><td>{{.Since.Format "2006-01-02 15:04:05"}} ({{.Age}})</td></tr>
+{{end}}
+</table>
+</body>
+</html>`
+
+var debugTemplate = template.Must(template.New("tinyrpc debug").Parse(debugText))
+
+// debugHTTP 展示注册的服务、每个方法的调用统计以及当前的连接
+type debugHTTP struct {
+	*Server
+}
+
+func (server debugHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	data := struct {
+		Services []debugService
+		Conns    []debugConn
+	}{}
+	for _, name := range server.serviceNames() {
+		sv, _ := server.services.Load(name)
+		s := sv.(*Service)
+		ds := debugService{Name: name}
+		for methodName, m := range s.method {
+			ds.Methods = append(ds.Methods, debugMethod{
+				Name:        methodName,
+				Argv:        m.argvType.String(),
+				Reply:       m.replyType.String(),
+				MethodStats: m.stats.snapshot(),
+			})
+		}
+		sort.Slice(ds.Methods, func(i, j int) bool { return ds.Methods[i].Name < ds.Methods[j].Name })
+		data.Services = append(data.Services, ds)
+	}
+
+	now := time.Now()
+	server.mu.Lock()
+	for _, info := range server.conns {
+		data.Conns = append(data.Conns, debugConn{
+			Peer:      info.peer.Addr.String(),
+			Codec:     info.codec,
+			TLS:       info.peer.TLS != nil,
+			Principal: info.principal,
+			Since:     info.since,
+			Age:       now.Sub(info.since).Round(time.Second),
+		})
+	}
+	server.mu.Unlock()
+	sort.Slice(data.Conns, func(i, j int) bool { return data.Conns[i].Since.Before(data.Conns[j].Since) })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := debugTemplate.Execute(w, data); err != nil {
+		server.log().Error("server error: executing debug template", "err", err)
+	}
+}
+
+// DebugHandler 返回调试页面的 http.Handler，可以挂载到任意路径
+func (server *Server) DebugHandler() http.Handler {
+	return debugHTTP{server}
+}
+
+// MountDebug 在 mux 的 DefaultDebugPath 上挂载调试页面。页面会展示服务和连接的内部信息，
+// 只应该挂载在不对外公开的 mux 上
+func (server *Server) MountDebug(mux *http.ServeMux) {
+	mux.Handle(DefaultDebugPath, server.DebugHandler())
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"tinyrpc/health"
+)
+
+// 健康检查协议的类型定义在 health 包中，这里保留原来的名称
+const HealthCheckMethod = health.CheckMethod
+
+type ServingStatus = health.ServingStatus
+
+const (
+	StatusUnknown    = health.StatusUnknown
+	StatusServing    = health.StatusServing
+	StatusNotServing = health.StatusNotServing
+)
+
+type HealthCheckRequest = health.CheckRequest
+
+type HealthCheckResponse = health.CheckResponse
+
+// HealthWatchRequest Wait 为 0 时使用 defaultWatchWait
+type HealthWatchRequest = health.WatchRequest
+
+const defaultWatchWait = 500 * time.Millisecond
+
+// watchReplyMargin Watch 在处理超时前提前返回的时间，留给服务端编码和发送响应
+const watchReplyMargin = 100 * time.Millisecond
+
+// Health 每个 Server 自动注册的健康检查服务，应用代码通过 Server.Health 设置各服务的状态
+type Health struct {
+	mu       sync.Mutex
+	statuses map[string]ServingStatus // 空字符串表示整个服务端
+	changed  chan struct{}            // 状态变化时关闭并替换，用于唤醒 Watch
+	shutdown bool
+}
+
+func newHealth() *Health {
+	return &Health{
+		statuses: map[string]ServingStatus{"": StatusServing},
+		changed:  make(chan struct{}),
+	}
+}
+
+// Check 返回服务当前的状态，服务未知时返回错误
+func (h *Health) Check(req *HealthCheckRequest, resp *HealthCheckResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status, ok := h.statuses[req.Service]
+	if !ok {
+		return errors.New("server error: unknown service " + req.Service)
+	}
+	resp.Status = status
+	return nil
+}
+
+// Watch 长轮询：阻塞直到服务状态与 LastStatus 不同，或等待超时后返回当前状态。
+// Wait 超过服务端的处理超时时，在超时前返回当前状态
+func (h *Health) Watch(ctx context.Context, req *HealthWatchRequest, resp *HealthCheckResponse) error {
+	wait := req.Wait
+	if wait <= 0 {
+		wait = defaultWatchWait
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline) - watchReplyMargin; wait > remaining {
+			wait = remaining
+		}
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		h.mu.Lock()
+		status, ok := h.statuses[req.Service]
+		if !ok {
+			status = StatusUnknown
+		}
+		changed := h.changed
+		h.mu.Unlock()
+		if status != req.LastStatus {
+			resp.Status = status
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			resp.Status = status
+			return nil
+		case <-timer.C:
+			resp.Status = status
+			return nil
+		}
+	}
+}
+
+// SetServingStatus 设置服务的状态，service 为空时设置整个服务端。
+// 服务端关闭后状态固定为 NOT_SERVING，不再允许修改。
+func (h *Health) SetServingStatus(service string, status ServingStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shutdown {
+		return
+	}
+	h.setLocked(service, status)
+}
+
+func (h *Health) setLocked(service string, status ServingStatus) {
+	if old, ok := h.statuses[service]; ok && old == status {
+		return
+	}
+	h.statuses[service] = status
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// Shutdown 将所有服务标记为 NOT_SERVING，由 Server.Shutdown 自动调用
+func (h *Health) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = true
+	for service := range h.statuses {
+		h.setLocked(service, StatusNotServing)
+	}
+}
+
+// Resume 恢复所有服务为 SERVING，并重新允许修改状态
+func (h *Health) Resume() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = false
+	for service := range h.statuses {
+		h.setLocked(service, StatusServing)
+	}
+}
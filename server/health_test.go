@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealthWatch(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&TestAdd{})
+	h := server.Health()
+
+	var resp HealthCheckResponse
+	if err := h.Check(&HealthCheckRequest{Service: "TestAdd"}, &resp); err != nil || resp.Status != StatusServing {
+		t.Fatalf("Check TestAdd: %v %v", resp.Status, err)
+	}
+	if err := h.Check(&HealthCheckRequest{Service: "Missing"}, &resp); err == nil {
+		t.Fatal("Check of unknown service should fail")
+	}
+
+	// 状态未变化时 Watch 等待超时后返回当前状态
+	start := time.Now()
+	_ = h.Watch(context.Background(), &HealthWatchRequest{Service: "TestAdd", LastStatus: StatusServing, Wait: 20 * time.Millisecond}, &resp)
+	if resp.Status != StatusServing || time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("Watch returned %v early", resp.Status)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		h.SetServingStatus("TestAdd", StatusNotServing)
+	}()
+	_ = h.Watch(context.Background(), &HealthWatchRequest{Service: "TestAdd", LastStatus: StatusServing, Wait: time.Second}, &resp)
+	if resp.Status != StatusNotServing {
+		t.Fatalf("Watch returned %v, want NOT_SERVING", resp.Status)
+	}
+
+	h.SetServingStatus("TestAdd", StatusServing)
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []string{"", "TestAdd"} {
+		_ = h.Check(&HealthCheckRequest{Service: service}, &resp)
+		if resp.Status != StatusNotServing {
+			t.Fatalf("%q after shutdown: %v", service, resp.Status)
+		}
+	}
+	h.SetServingStatus("TestAdd", StatusServing)
+	_ = h.Check(&HealthCheckRequest{Service: "TestAdd"}, &resp)
+	if resp.Status != StatusNotServing {
+		t.Fatal("status changed after shutdown")
+	}
+}
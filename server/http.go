@@ -0,0 +1,56 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"tinyrpc/codec"
+)
+
+const (
+	// DefaultRPCPath HandleHTTP 挂载的路径，见 codec.DefaultRPCPath
+	DefaultRPCPath = codec.DefaultRPCPath
+	// Connected CONNECT 成功时返回的状态，见 codec.Connected
+	Connected = codec.Connected
+)
+
+// ServeHTTP 接管 CONNECT 请求的连接，之后按照普通连接处理，
+// 这样 RPC 可以和注册中心等 HTTP 服务共用同一个端口
+func (server *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodConnect {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "server error: connection can't be hijacked", http.StatusInternalServerError)
+		return
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		server.log().Error("server error: hijack", "peer", req.RemoteAddr, "err", err)
+		return
+	}
+	if brw.Reader.Buffered() > 0 {
+		// 客户端在 CONNECT 之后紧跟着发送的数据已经被读入缓冲
+		conn = &bufferedConn{Conn: conn, r: brw.Reader}
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.0 "+Connected+"\n\n")
+	server.ServeConn(conn)
+}
+
+// Mount 将服务端挂载到 mux 的 DefaultRPCPath 和 DefaultWebSocketPath 上，调试页面需要通过 MountDebug 挂载
+func (server *Server) Mount(mux *http.ServeMux) {
+	mux.Handle(DefaultRPCPath, server)
+	mux.Handle(DefaultWebSocketPath, server.WebSocketHandler())
+}
+
+// HandleHTTP 将服务端挂载到 http.DefaultServeMux 上
+func (server *Server) HandleHTTP() {
+	server.Mount(http.DefaultServeMux)
+}
+
+func HandleHTTP() {
+	defaultServer.HandleHTTP()
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net"
+	"time"
+	"tinyrpc/inprocess"
+)
+
+// InProcessNetwork 进程内连接的网络名称，地址格式为 inproc@name
+const InProcessNetwork = inprocess.Network
+
+// ListenInProcess 创建名为 name 的进程内 listener，同名的 listener 关闭前不能重复创建
+func ListenInProcess(name string) (net.Listener, error) {
+	return inprocess.Listen(name)
+}
+
+// DialInProcess 连接名为 name 的进程内 listener，返回客户端一端的连接，timeout 为 0 时不限制
+func DialInProcess(name string, timeout time.Duration) (net.Conn, error) {
+	return inprocess.Dial(name, timeout)
+}
+
+// ServeInProcess 在后台接受名为 name 的进程内连接，客户端通过 client.DialInProcess 连接，
+// 数据不经过网络。关闭返回的 listener 或 Shutdown 后停止服务
+func (server *Server) ServeInProcess(name string) (net.Listener, error) {
+	lis, err := ListenInProcess(name)
+	if err != nil {
+		return nil, err
+	}
+	go server.Accept(lis)
+	return lis, nil
+}
+
+func ServeInProcess(name string) (net.Listener, error) {
+	return defaultServer.ServeInProcess(name)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"tinyrpc/metadata"
+)
+
+// RequestInfo 拦截器中可以读取的请求信息
+type RequestInfo struct {
+	ServiceMethod string
+	Service       string
+	Method        string
+}
+
+// Handler 调用服务方法，argv 和 reply 为方法的参数和返回值
+type Handler func(ctx context.Context, argv interface{}, reply interface{}) error
+
+// Interceptor 包裹服务方法的调用，在调用 next 前后执行额外的逻辑，
+// 不调用 next 时直接返回错误即可拒绝请求
+type Interceptor func(ctx context.Context, info *RequestInfo, argv interface{}, reply interface{}, next Handler) error
+
+// chain 将拦截器依次包裹在 handler 外面
+func (server *Server) chain(info *RequestInfo, handler Handler) Handler {
+	for i := len(server.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := server.interceptors[i], handler
+		handler = func(ctx context.Context, argv interface{}, reply interface{}) error {
+			return interceptor(ctx, info, argv, reply, next)
+		}
+	}
+	return handler
+}
+
+type trailerKey struct{}
+
+// trailer 一次请求中 handler 设置的返回元数据
+type trailer struct {
+	mu sync.Mutex
+	md metadata.MD
+}
+
+// SetTrailer 在 handler 或拦截器中设置随响应返回给客户端的元数据，多次调用会合并
+func SetTrailer(ctx context.Context, md metadata.MD) error {
+	t, ok := ctx.Value(trailerKey{}).(*trailer)
+	if !ok {
+		return errors.New("server error: no trailer in context")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.md = metadata.Join(t.md, md)
+	return nil
+}
+
+func (t *trailer) get() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.md
+}
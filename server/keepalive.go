@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"time"
+	"tinyrpc/codec"
+	"tinyrpc/status"
+)
+
+const (
+	defaultMinPingInterval = 5 * time.Second
+	// maxPingStrikes 允许过于频繁的 ping 的次数，超过后关闭连接
+	maxPingStrikes = 2
+)
+
+// connActivity 记录连接上的请求和 ping，用于关闭空闲连接和限制 ping 的频率
+type connActivity struct {
+	mu          sync.Mutex
+	active      int // 正在处理的请求数
+	lastActive  time.Time
+	lastPing    time.Time
+	pingStrikes int
+}
+
+func newConnActivity() *connActivity {
+	return &connActivity{lastActive: time.Now()}
+}
+
+func (a *connActivity) begin() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active++
+	a.lastActive = time.Now()
+}
+
+func (a *connActivity) end() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active--
+	a.lastActive = time.Now()
+}
+
+// idle 没有正在处理的请求时返回已经空闲的时间
+func (a *connActivity) idle() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active > 0 {
+		return 0
+	}
+	return time.Since(a.lastActive)
+}
+
+// ping 记录一次 ping，间隔小于 min 的次数超过 maxPingStrikes 时返回 false
+func (a *connActivity) ping(min time.Duration) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if min > 0 && !a.lastPing.IsZero() && now.Sub(a.lastPing) < min {
+		a.pingStrikes++
+	}
+	a.lastPing = now
+	return a.pingStrikes <= maxPingStrikes
+}
+
+func (server *Server) pingInterval() time.Duration {
+	if server.minPingInterval == 0 {
+		return defaultMinPingInterval
+	}
+	return server.minPingInterval
+}
+
+// handlePing 回复 pong，ping 过于频繁时通知客户端并返回 false，由调用方关闭连接
+func (server *Server) handlePing(conn net.Conn, c codec.Codec, activity *connActivity, send *sync.Mutex) bool {
+	if !activity.ping(server.pingInterval()) {
+		server.log().Warn("server error: too many pings", "peer", conn.RemoteAddr().String())
+		server.sendError(c, &codec.Header{}, status.New(status.ResourceExhausted, "server error: too many pings"), send)
+		return false
+	}
+	pong := &Request{header: &codec.Header{ServiceMethod: codec.PongMethod}, reply: reflect.ValueOf("pong")}
+	server.SendResponse(c, pong, send)
+	return true
+}
+
+// watchIdle 连接空闲超过 idleTimeout 时通知客户端并关闭连接，done 关闭后退出
+func (server *Server) watchIdle(conn net.Conn, c codec.Codec, activity *connActivity, send *sync.Mutex, done <-chan struct{}) {
+	timer := time.NewTimer(server.idleTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+		if idle := activity.idle(); idle < server.idleTimeout {
+			timer.Reset(server.idleTimeout - idle)
+			continue
+		}
+		server.log().Info("server: close idle connection", "peer", conn.RemoteAddr().String())
+		server.sendError(c, &codec.Header{}, status.New(status.Unavailable, "server error: connection idle timeout"), send)
+		_ = c.Close()
+		return
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnActivityPing(t *testing.T) {
+	a := newConnActivity()
+	for i := 0; i <= maxPingStrikes; i++ {
+		if !a.ping(time.Hour) {
+			t.Fatalf("ping %d should be allowed", i)
+		}
+	}
+	if a.ping(time.Hour) {
+		t.Fatal("ping should be rejected after too many strikes")
+	}
+
+	a = newConnActivity()
+	for i := 0; i < 10; i++ {
+		if !a.ping(-1) {
+			t.Fatal("ping should always be allowed when the interval is not enforced")
+		}
+	}
+}
+
+func TestConnActivityIdle(t *testing.T) {
+	a := newConnActivity()
+	a.begin()
+	time.Sleep(20 * time.Millisecond)
+	if idle := a.idle(); idle != 0 {
+		t.Fatalf("connection with active requests reported idle for %v", idle)
+	}
+	a.end()
+	time.Sleep(20 * time.Millisecond)
+	if idle := a.idle(); idle < 20*time.Millisecond {
+		t.Fatalf("idle %v", idle)
+	}
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"sync"
+	"tinyrpc/codec"
+	"tinyrpc/status"
+)
+
+// rejectOversized 通知客户端请求过大。由于没有消息边界，之后的数据无法继续读取，调用方需要关闭连接。
+// header 也没有读完时用 Num 为 0 的连接级错误通知
+func (server *Server) rejectOversized(c codec.Codec, request *Request, send *sync.Mutex) {
+	err := status.Errorf(status.ResourceExhausted, "server error: request larger than %d bytes", server.maxRequestSize)
+	header := &codec.Header{}
+	if request != nil {
+		server.metrics.reject(request, err)
+		header = request.header
+	}
+	server.log().Warn("server error: request too large", "method", header.ServiceMethod, "limit", server.maxRequestSize)
+	server.sendError(c, header, err, send)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"time"
+	"tinyrpc/metrics"
+	"tinyrpc/status"
+)
+
+// serverMetrics 服务端上报的指标
+type serverMetrics struct {
+	requests    metrics.Counter
+	latency     metrics.Histogram
+	inflight    metrics.Gauge
+	connections metrics.Gauge
+	bytesIn     metrics.Counter
+	bytesOut    metrics.Counter
+	reqSize     metrics.Histogram
+	respSize    metrics.Histogram
+}
+
+func newServerMetrics(p metrics.Provider) *serverMetrics {
+	if p == nil {
+		p = metrics.Discard
+	}
+	return &serverMetrics{
+		requests:    p.NewCounter("tinyrpc_server_requests_total", "Requests handled by the server.", "service", "method", "code"),
+		latency:     p.NewHistogram("tinyrpc_server_request_duration_seconds", "Time spent handling requests.", nil, "service", "method"),
+		inflight:    p.NewGauge("tinyrpc_server_in_flight_requests", "Requests currently being handled.", "service", "method"),
+		connections: p.NewGauge("tinyrpc_server_connections", "Open client connections."),
+		bytesIn:     p.NewCounter("tinyrpc_server_received_bytes_total", "Bytes read from client connections."),
+		bytesOut:    p.NewCounter("tinyrpc_server_sent_bytes_total", "Bytes written to client connections."),
+		reqSize:     p.NewHistogram("tinyrpc_server_request_size_bytes", "Size of requests read from clients, including the header.", metrics.SizeBuckets, "service", "method"),
+		respSize:    p.NewHistogram("tinyrpc_server_response_size_bytes", "Size of responses written to clients, including the header.", metrics.SizeBuckets, "service", "method"),
+	}
+}
+
+// begin 记录请求开始，返回请求结束时调用的函数
+func (m *serverMetrics) begin(info *RequestInfo) func(code status.Code) {
+	start := time.Now()
+	m.inflight.Add(1, info.Service, info.Method)
+	return func(code status.Code) {
+		m.inflight.Add(-1, info.Service, info.Method)
+		m.requests.Add(1, info.Service, info.Method, code.String())
+		m.latency.Observe(time.Since(start).Seconds(), info.Service, info.Method)
+	}
+}
+
+// reject 记录没有进入 HandleRequest 就被拒绝的请求，方法不存在时 service 和 method 记为 unknown
+func (m *serverMetrics) reject(request *Request, err error) {
+	service, method := "unknown", "unknown"
+	if request.service != nil && request.method != nil {
+		service, method = request.service.name, request.method.method.Name
+	}
+	m.requests.Add(1, service, method, status.CodeOf(err).String())
+}
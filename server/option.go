@@ -0,0 +1,165 @@
+package server
+
+import (
+	"net/http"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/logging"
+	"tinyrpc/metrics"
+	"tinyrpc/trace"
+)
+
+// Option 用于在 NewServer 时配置 Server
+type Option func(server *Server)
+
+// WithInterceptor 添加拦截器，先添加的拦截器在外层
+func WithInterceptor(interceptors ...Interceptor) Option {
+	return func(server *Server) {
+		server.interceptors = append(server.interceptors, interceptors...)
+	}
+}
+
+// WithAuthenticator 要求客户端在握手时提交凭证，认证失败的连接会收到 Unauthenticated 错误并被关闭
+func WithAuthenticator(authenticator auth.Authenticator) Option {
+	return func(server *Server) {
+		server.authenticator = authenticator
+	}
+}
+
+// WithAuthorizer 在执行拦截器和服务方法前检查调用方的权限，拒绝时返回 PermissionDenied 错误。
+// 内置的 Health 和 Reflection 服务同样受策略约束
+func WithAuthorizer(authorizer auth.Authorizer) Option {
+	return func(server *Server) {
+		server.authorizer = authorizer
+	}
+}
+
+// WithMetrics 上报请求数、耗时、连接数和流量等指标，例如 metrics.Default
+func WithMetrics(provider metrics.Provider) Option {
+	return func(server *Server) {
+		server.provider = provider
+	}
+}
+
+// WithTracer 为每个请求创建 server span，请求元数据中带有 traceparent 时作为上游 span 的子 span，
+// handler 中使用同一个 ctx 发起的调用会成为 server span 的子 span
+func WithTracer(tracer *trace.Tracer) Option {
+	return func(server *Server) {
+		server.tracer = tracer
+	}
+}
+
+// WithLogger 指定输出日志的 Logger，默认使用 logging.Default()
+func WithLogger(logger logging.Logger) Option {
+	return func(server *Server) {
+		server.logger = logger
+	}
+}
+
+// WithIdleTimeout 关闭超过 timeout 没有请求的连接，客户端的 ping 不计入，关闭前会通知客户端。
+// 默认不关闭空闲连接
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(server *Server) {
+		server.idleTimeout = timeout
+	}
+}
+
+// WithMinPingInterval 客户端 ping 的最小间隔，多次过于频繁的 ping 会导致连接被关闭。
+// 默认为 5 秒，interval 为负数时不限制
+func WithMinPingInterval(interval time.Duration) Option {
+	return func(server *Server) {
+		server.minPingInterval = interval
+	}
+}
+
+// WithHandleTimeout 每个请求的处理超时，超时后客户端收到 DeadlineExceeded 错误，默认为 DefaultHandleTimeout
+func WithHandleTimeout(timeout time.Duration) Option {
+	return func(server *Server) {
+		server.handleTimeout = timeout
+	}
+}
+
+// WithMaxRequestSize 收到的请求（header 和 body）的最大字节数，超过时返回 ResourceExhausted 错误并关闭连接，
+// 不会读入整个请求。默认不限制
+func WithMaxRequestSize(n int) Option {
+	return func(server *Server) {
+		server.maxRequestSize = int64(n)
+	}
+}
+
+// WithMaxResponseSize 发送的响应（header 和 body）的最大字节数，超过时不发送该响应，
+// 客户端收到 ResourceExhausted 错误，连接可以继续使用。默认不限制
+func WithMaxResponseSize(n int) Option {
+	return func(server *Server) {
+		server.maxResponseSize = int64(n)
+	}
+}
+
+// WithMaxConcurrentRequests 整个服务端同时处理的请求数，由 n 个 worker 执行，
+// 超出的请求排队，队列满时返回 Unavailable 错误。默认不限制，每个请求启动一个协程。
+// 内置的 Health 和 Reflection 服务不受任何并发限制影响
+func WithMaxConcurrentRequests(n int) Option {
+	return func(server *Server) {
+		server.maxConcurrent = n
+	}
+}
+
+// WithMaxConnConcurrentRequests 每个连接同时处理的请求数，超出的请求排队，队列满时返回 ResourceExhausted 错误
+func WithMaxConnConcurrentRequests(n int) Option {
+	return func(server *Server) {
+		server.maxConnConcurrent = n
+	}
+}
+
+// WithMethodConcurrency 方法同时处理的请求数，serviceMethod 形如 Service.Method，
+// 超出的请求排队，队列满时返回 ResourceExhausted 错误
+func WithMethodConcurrency(serviceMethod string, n int) Option {
+	return func(server *Server) {
+		if server.methodConcurrency == nil {
+			server.methodConcurrency = make(map[string]int)
+		}
+		server.methodConcurrency[serviceMethod] = n
+	}
+}
+
+// WithQueueSize 以上每一级并发限制排队的请求数上限，默认为 DefaultQueueSize，n 为负数时不排队
+func WithQueueSize(n int) Option {
+	return func(server *Server) {
+		server.queueLen = n
+	}
+}
+
+// WithRateLimit 整个服务端每秒允许 rate 次调用，最多允许 burst 次突发调用，
+// 超过时返回带有重试等待时间的 RateLimited 错误。rate 小于等于 0 时不限制，
+// 内置的 Health 和 Reflection 服务不受任何限流影响
+func WithRateLimit(rate float64, burst int) Option {
+	return func(server *Server) {
+		server.rateLimit = rateConfig{rate: rate, burst: burst}
+	}
+}
+
+// WithMethodRateLimit 方法每秒允许 rate 次调用，最多允许 burst 次突发调用，serviceMethod 形如 Service.Method
+func WithMethodRateLimit(serviceMethod string, rate float64, burst int) Option {
+	return func(server *Server) {
+		if server.methodRateLimits == nil {
+			server.methodRateLimits = make(map[string]rateConfig)
+		}
+		server.methodRateLimits[serviceMethod] = rateConfig{rate: rate, burst: burst}
+	}
+}
+
+// WithClientRateLimit 每个调用方每秒允许 rate 次调用，最多允许 burst 次突发调用。
+// 认证过的连接按调用方名称计算，同一调用方的所有连接共享限额，否则按对端的主机地址计算
+func WithClientRateLimit(rate float64, burst int) Option {
+	return func(server *Server) {
+		server.clientRateLimit = rateConfig{rate: rate, burst: burst}
+	}
+}
+
+// WithCheckOrigin 检查 WebSocket 握手请求的 Origin，返回 false 时拒绝连接。
+// 默认为 websocket.SameOrigin，只接受没有 Origin 或与 Host 同源的请求
+func WithCheckOrigin(check func(req *http.Request) bool) Option {
+	return func(server *Server) {
+		server.checkOrigin = check
+	}
+}
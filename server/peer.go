@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+)
+
+// Peer 发起请求的连接信息
+type Peer struct {
+	Addr net.Addr
+	TLS  *tls.ConnectionState // 非 TLS 连接时为 nil
+}
+
+type peerKey struct{}
+
+// PeerFromContext 在 handler 和拦截器中读取对端的地址以及 TLS 信息，
+// mTLS 时 TLS.PeerCertificates[0] 为已经验证过的客户端证书
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
+
+const tlsHandshakeTimeout = 10 * time.Second
+
+// newPeer 完成 TLS 握手并记录连接信息
+func newPeer(conn net.Conn) (*Peer, error) {
+	p := &Peer{Addr: conn.RemoteAddr()}
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return p, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tlsHandshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+	p.TLS = &state
+	return p, nil
+}
+
+// ServeTLS 在 lis 上接受 TLS 连接，config 需要设置 Certificates 或 GetCertificate，
+// 设置 ClientAuth 为 tls.RequireAndVerifyClientCert 即为 mTLS
+func (server *Server) ServeTLS(lis net.Listener, config *tls.Config) {
+	server.Accept(tls.NewListener(lis, config))
+}
+
+// ListenAndServeTLS 监听 network 上的地址并接受 TLS 连接
+func (server *Server) ListenAndServeTLS(network string, addr string, config *tls.Config) error {
+	if config == nil || (len(config.Certificates) == 0 && config.GetCertificate == nil && config.GetConfigForClient == nil) {
+		return errors.New("server error: tls config has no certificate")
+	}
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		server.log().Error("server error: listen", "addr", addr, "err", err)
+		return err
+	}
+	server.ServeTLS(lis, config)
+	return nil
+}
+
+func ServeTLS(lis net.Listener, config *tls.Config) {
+	defaultServer.ServeTLS(lis, config)
+}
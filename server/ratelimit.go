@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/status"
+)
+
+// clientSweepInterval 清理已经补满的调用方令牌桶的间隔
+const clientSweepInterval = time.Minute
+
+// rateConfig 令牌桶的配置，每秒补充 rate 个令牌，最多积累 burst 个
+type rateConfig struct {
+	rate  float64
+	burst int
+}
+
+// tokenBucket 令牌桶，每次调用取出一个令牌
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(config rateConfig, now time.Time) *tokenBucket {
+	burst := float64(config.burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: config.rate, burst: burst, tokens: burst, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// take 取出一个令牌，没有令牌时返回补充一个令牌需要等待的时间
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// put 归还 take 取出的令牌，用于之后的限流拒绝了该请求时
+func (b *tokenBucket) put() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// full 令牌已经补满，与新建的令牌桶没有区别
+func (b *tokenBucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// rateLimiter 依次检查调用方、方法和全局的令牌桶，任何一个没有令牌时拒绝请求
+type rateLimiter struct {
+	global  *tokenBucket
+	methods map[string]*tokenBucket
+	client  rateConfig
+
+	mu        sync.Mutex
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// initRateLimit 根据配置创建限流器，没有配置任何限流时为 nil
+func (server *Server) initRateLimit() {
+	if server.rateLimit.rate <= 0 && server.clientRateLimit.rate <= 0 && len(server.methodRateLimits) == 0 {
+		return
+	}
+	now := time.Now()
+	l := &rateLimiter{
+		methods:   make(map[string]*tokenBucket, len(server.methodRateLimits)),
+		client:    server.clientRateLimit,
+		clients:   make(map[string]*tokenBucket),
+		lastSweep: now,
+	}
+	if server.rateLimit.rate > 0 {
+		l.global = newTokenBucket(server.rateLimit, now)
+	}
+	for serviceMethod, config := range server.methodRateLimits {
+		if config.rate > 0 {
+			l.methods[serviceMethod] = newTokenBucket(config, now)
+		}
+	}
+	server.limiter = l
+}
+
+// clientKey 认证过的连接按调用方名称限流，否则按对端的主机地址限流，同一主机的多个连接共享令牌
+func clientKey(ctx context.Context) string {
+	if principal, ok := auth.FromContext(ctx); ok && principal != nil && principal.Name != "" {
+		return "principal " + principal.Name
+	}
+	peer, ok := PeerFromContext(ctx)
+	if !ok || peer.Addr == nil {
+		return "peer unknown"
+	}
+	addr := peer.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return "peer " + addr
+}
+
+// clientBucket 返回调用方的令牌桶，并定期清理已经补满的令牌桶
+func (l *rateLimiter) clientBucket(key string, now time.Time) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= clientSweepInterval {
+		for k, b := range l.clients {
+			if b.full(now) {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.clients[key]
+	if !ok {
+		b = newTokenBucket(l.client, now)
+		l.clients[key] = b
+	}
+	return b
+}
+
+// allow 为请求取出令牌，被拒绝时返回带有重试等待时间的 RateLimited 错误
+func (l *rateLimiter) allow(ctx context.Context, serviceMethod string) error {
+	now := time.Now()
+	type scoped struct {
+		bucket *tokenBucket
+		scope  string
+	}
+	var buckets []scoped
+	if l.client.rate > 0 {
+		key := clientKey(ctx)
+		buckets = append(buckets, scoped{l.clientBucket(key, now), key})
+	}
+	if b, ok := l.methods[serviceMethod]; ok {
+		buckets = append(buckets, scoped{b, "method " + serviceMethod})
+	}
+	if l.global != nil {
+		buckets = append(buckets, scoped{l.global, "server"})
+	}
+	for i, s := range buckets {
+		ok, wait := s.bucket.take(now)
+		if ok {
+			continue
+		}
+		for _, taken := range buckets[:i] {
+			taken.bucket.put()
+		}
+		return status.New(status.RateLimited, "server error: rate limit exceeded for "+s.scope).WithRetryAfter(wait)
+	}
+	return nil
+}
+
+// allowRate 在分发请求前检查限流。内置服务不限流，注册中心和连接池的健康检查
+// 不能因为业务流量被拒绝，否则健康的实例会被摘除
+func (server *Server) allowRate(ctx context.Context, request *Request) error {
+	if server.limiter == nil || request.service.builtin {
+		return nil
+	}
+	return server.limiter.allow(ctx, request.header.ServiceMethod)
+}
+
+// retryAfterMillis 错误中的重试等待时间，向上取整为毫秒
+func retryAfterMillis(err error) int64 {
+	d := status.RetryAfterOf(err)
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
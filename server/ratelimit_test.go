@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/codec"
+	"tinyrpc/status"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(rateConfig{rate: 10, burst: 2}, now)
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.take(now); !ok {
+			t.Fatalf("take %d should succeed within the burst", i)
+		}
+	}
+	ok, wait := b.take(now)
+	if ok || wait != 100*time.Millisecond {
+		t.Fatalf("expected to wait 100ms, got ok %v wait %v", ok, wait)
+	}
+	if ok, _ := b.take(now.Add(100 * time.Millisecond)); !ok {
+		t.Fatal("a token should be added after 100ms")
+	}
+	if b.full(now.Add(150 * time.Millisecond)) {
+		t.Fatal("bucket should not be full yet")
+	}
+	if !b.full(now.Add(time.Second)) {
+		t.Fatal("bucket should be full after a second")
+	}
+}
+
+func TestRateLimiterRefund(t *testing.T) {
+	server := NewServer(WithClientRateLimit(1, 5), WithMethodRateLimit("Foo.Bar", 1, 1))
+	ctx := context.WithValue(context.Background(), peerKey{}, &Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+	if err := server.allowRate(ctx, rateRequest("Foo.Bar", false)); err != nil {
+		t.Fatal(err)
+	}
+	err := server.allowRate(ctx, rateRequest("Foo.Bar", false))
+	if status.CodeOf(err) != status.RateLimited || status.RetryAfterOf(err) <= 0 {
+		t.Fatalf("expected RateLimited with a retry hint, got %v", err)
+	}
+	// 被方法限流拒绝的请求不消耗调用方的令牌
+	for i := 0; i < 4; i++ {
+		if err := server.allowRate(ctx, rateRequest("Foo.Other", false)); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if err := server.allowRate(ctx, rateRequest("Foo.Other", false)); status.CodeOf(err) != status.RateLimited {
+		t.Fatalf("expected the caller to be limited, got %v", err)
+	}
+	// 内置服务不受限流影响，也不消耗令牌
+	if err := server.allowRate(ctx, rateRequest(HealthCheckMethod, true)); err != nil {
+		t.Fatalf("built-in services should not be limited, got %v", err)
+	}
+}
+
+func rateRequest(serviceMethod string, builtin bool) *Request {
+	return &Request{header: &codec.Header{ServiceMethod: serviceMethod}, service: &Service{builtin: builtin}}
+}
+
+func TestClientKey(t *testing.T) {
+	peer := &Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}}
+	ctx := context.WithValue(context.Background(), peerKey{}, peer)
+	if key := clientKey(ctx); key != "peer 10.0.0.1" {
+		t.Fatalf("unexpected key %q", key)
+	}
+	ctx = auth.NewContext(ctx, &auth.Principal{Name: "alice"})
+	if key := clientKey(ctx); key != "principal alice" {
+		t.Fatalf("unexpected key %q", key)
+	}
+}
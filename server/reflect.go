@@ -1,15 +1,23 @@
 package server
 
 import (
-	"log"
+	"context"
 	"reflect"
+	"tinyrpc/logging"
+)
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
 )
 
 // serviceMethod 记录每个服务的方法的类型
 type serviceMethod struct {
-	method    reflect.Method
-	argvType  reflect.Type
-	replyType reflect.Type
+	method      reflect.Method
+	argvType    reflect.Type
+	replyType   reflect.Type
+	withContext bool // 方法的第一个参数为 context.Context
+	stats       methodStats
 }
 
 // 通过serviceMethod中Argv和Reply类型返回对应的reflect.Value
@@ -33,36 +41,52 @@ type Service struct {
 	serviceType  reflect.Type
 	serviceValue reflect.Value
 	method       map[string]*serviceMethod
+	builtin      bool // 内置的 Health 和 Reflection 服务，不受限流和并发限制影响
 }
 
 // NewService 创建service实例，并且将service对应的方法注册到service中
 func NewService(serviceValue interface{}) *Service {
+	return newService(serviceValue, logging.Default())
+}
+
+func newService(serviceValue interface{}, logger logging.Logger) *Service {
 	s := new(Service)
 	s.serviceValue = reflect.ValueOf(serviceValue)
 	s.serviceType = reflect.TypeOf(serviceValue)
-	log.Printf("serviceType %v serviceValue %v\n", s.serviceType, s.serviceValue)
 	s.name = s.serviceType.Elem().Name()
 	s.method = make(map[string]*serviceMethod)
 	for i := 0; i < s.serviceType.NumMethod(); i++ {
 		serviceMethodType := s.serviceType.Method(i).Type
+		// 支持 (argv, reply) error 和 (ctx, argv, reply) error 两种形式
+		withContext := serviceMethodType.NumIn() == 4 && serviceMethodType.In(1) == contextType
+		in := 1
+		if withContext {
+			in = 2
+		}
 
-		if serviceMethodType.NumIn() != 3 || serviceMethodType.NumOut() != 1 {
-			log.Printf("service %v method %v wrong format\n", s.serviceType.Name(), serviceMethodType.Name())
+		if serviceMethodType.NumIn() != in+2 || serviceMethodType.NumOut() != 1 ||
+			serviceMethodType.In(in+1).Kind() != reflect.Ptr || serviceMethodType.Out(0) != errorType {
+			logger.Debug("server: skip method with wrong format", "service", s.name, "method", s.serviceType.Method(i).Name)
 			continue
 		}
 		//得到service对应每个方法的reflect.Type
 		s.method[s.serviceType.Method(i).Name] = &serviceMethod{
-			argvType:  serviceMethodType.In(1),
-			replyType: serviceMethodType.In(2),
-			method:    s.serviceType.Method(i),
+			argvType:    serviceMethodType.In(in),
+			replyType:   serviceMethodType.In(in + 1),
+			method:      s.serviceType.Method(i),
+			withContext: withContext,
 		}
-		log.Printf("service %v register %v\n", s.name, s.serviceType.Method(i).Name)
+		logger.Debug("server: register method", "service", s.name, "method", s.serviceType.Method(i).Name)
 	}
 	return s
 }
-func (s *Service) call(m *serviceMethod, argv reflect.Value, reply reflect.Value) error {
+func (s *Service) call(ctx context.Context, m *serviceMethod, argv reflect.Value, reply reflect.Value) error {
 	fc := m.method.Func
-	errorValues := fc.Call([]reflect.Value{s.serviceValue, argv, reply})
+	in := []reflect.Value{s.serviceValue, argv, reply}
+	if m.withContext {
+		in = []reflect.Value{s.serviceValue, reflect.ValueOf(ctx), argv, reply}
+	}
+	errorValues := fc.Call(in)
 	errorValue := errorValues[0].Interface()
 	if errorValue != nil {
 		return errorValue.(error)
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"log"
 	"reflect"
@@ -36,11 +37,11 @@ func TestReflect(t *testing.T) {
 	a := Argv{a: 1, b: 5}
 	argv.Set(reflect.ValueOf(a))
 
-	err := s.call(serviceMethodAdd, argv, reply)
+	err := s.call(context.Background(), serviceMethodAdd, argv, reply)
 	log.Printf("reply %v error %v", reply, err)
 
 	serviceMethodError := s.method["ReturnError"]
 
-	err = s.call(serviceMethodError, argv, reply)
+	err = s.call(context.Background(), serviceMethodError, argv, reply)
 	log.Printf("---reply %v error %v", reply, err)
 }
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// TypeDescriptor 描述参数和返回值的类型结构，调用方不需要 Go 类型即可构造请求。
+// Kind 与 reflect.Kind 的名称一致，ptr/slice/array/map 的元素类型记录在 Elem 中，
+// 递归引用已经展开过的具名类型时只填写 Name 和 Kind。
+type TypeDescriptor struct {
+	Name   string // 具名类型的完整名称，如 test.Argv
+	Kind   string
+	Elem   *TypeDescriptor
+	Key    *TypeDescriptor // map 的键类型
+	Len    int             // array 的长度
+	Fields []FieldDescriptor
+}
+
+type FieldDescriptor struct {
+	Name string
+	Type *TypeDescriptor
+}
+
+type MethodDescriptor struct {
+	Name  string
+	Argv  *TypeDescriptor
+	Reply *TypeDescriptor
+}
+
+type ServiceDescriptor struct {
+	Name    string
+	Methods []MethodDescriptor
+}
+
+type ListServicesRequest struct{}
+
+type ListServicesResponse struct {
+	Services []string
+}
+
+type DescribeServiceRequest struct {
+	Service string
+}
+
+// Reflection 每个 Server 自动注册的反射服务，用于列出服务、方法以及参数类型
+type Reflection struct {
+	server *Server
+}
+
+func (r *Reflection) ListServices(req *ListServicesRequest, resp *ListServicesResponse) error {
+	resp.Services = r.server.serviceNames()
+	return nil
+}
+
+func (r *Reflection) DescribeService(req *DescribeServiceRequest, resp *ServiceDescriptor) error {
+	sv, ok := r.server.services.Load(req.Service)
+	if !ok {
+		return errors.New("server error: can`t find service " + req.Service)
+	}
+	*resp = *sv.(*Service).describe()
+	return nil
+}
+
+func (server *Server) serviceNames() []string {
+	server.init()
+	var names []string
+	server.services.Range(func(key, _ interface{}) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+func (s *Service) describe() *ServiceDescriptor {
+	d := &ServiceDescriptor{Name: s.name}
+	for name, m := range s.method {
+		d.Methods = append(d.Methods, MethodDescriptor{
+			Name:  name,
+			Argv:  DescribeType(m.argvType),
+			Reply: DescribeType(m.replyType),
+		})
+	}
+	sort.Slice(d.Methods, func(i, j int) bool {
+		return d.Methods[i].Name < d.Methods[j].Name
+	})
+	return d
+}
+
+// DescribeType 生成类型的描述，只包含可以被编码的导出字段
+func DescribeType(t reflect.Type) *TypeDescriptor {
+	return describeType(t, make(map[reflect.Type]bool))
+}
+
+func describeType(t reflect.Type, seen map[reflect.Type]bool) *TypeDescriptor {
+	d := &TypeDescriptor{Kind: t.Kind().String()}
+	if t.Name() != "" {
+		d.Name = t.String()
+		if seen[t] {
+			return d
+		}
+		seen[t] = true
+		defer delete(seen, t)
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		d.Elem = describeType(t.Elem(), seen)
+	case reflect.Array:
+		d.Len = t.Len()
+		d.Elem = describeType(t.Elem(), seen)
+	case reflect.Map:
+		d.Key = describeType(t.Key(), seen)
+		d.Elem = describeType(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			d.Fields = append(d.Fields, FieldDescriptor{Name: f.Name, Type: describeType(f.Type, seen)})
+		}
+	}
+	return d
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"testing"
+)
+
+type Node struct {
+	Value    int
+	Children []*Node
+	Tags     map[string]bool
+	hidden   int
+}
+
+type Tree struct{}
+
+func (t *Tree) Walk(argv *Node, reply *[]int) error {
+	return nil
+}
+
+func TestReflection(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Tree{})
+	r := &Reflection{server: server}
+
+	var services ListServicesResponse
+	_ = r.ListServices(&ListServicesRequest{}, &services)
+	want := []string{"Health", "Reflection", "Tree"}
+	if len(services.Services) != len(want) {
+		t.Fatalf("services %v, want %v", services.Services, want)
+	}
+	for i := range want {
+		if services.Services[i] != want[i] {
+			t.Fatalf("services %v, want %v", services.Services, want)
+		}
+	}
+
+	var desc ServiceDescriptor
+	if err := r.DescribeService(&DescribeServiceRequest{Service: "Tree"}, &desc); err != nil {
+		t.Fatal(err)
+	}
+	if len(desc.Methods) != 1 || desc.Methods[0].Name != "Walk" {
+		t.Fatalf("methods %+v", desc.Methods)
+	}
+	argv := desc.Methods[0].Argv
+	if argv.Kind != "ptr" || argv.Elem.Name != "server.Node" || len(argv.Elem.Fields) != 3 {
+		t.Fatalf("argv %+v", argv.Elem)
+	}
+	children := argv.Elem.Fields[1].Type
+	// 递归引用只保留名称
+	if children.Kind != "slice" || children.Elem.Elem.Name != "server.Node" || children.Elem.Elem.Fields != nil {
+		t.Fatalf("children %+v", children.Elem.Elem)
+	}
+	tags := argv.Elem.Fields[2].Type
+	if tags.Key.Kind != "string" || tags.Elem.Kind != "bool" {
+		t.Fatalf("tags %+v", tags)
+	}
+	if reply := desc.Methods[0].Reply; reply.Elem.Kind != "slice" || reply.Elem.Elem.Kind != "int" {
+		t.Fatalf("reply %+v", reply)
+	}
+
+	if err := r.DescribeService(&DescribeServiceRequest{Service: "Missing"}, &desc); err == nil {
+		t.Fatal("describe of unknown service should fail")
+	}
+}
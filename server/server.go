@@ -1,161 +1,607 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"tinyrpc/auth"
 	"tinyrpc/codec"
+	"tinyrpc/logging"
+	"tinyrpc/metadata"
+	"tinyrpc/metrics"
+	"tinyrpc/status"
+	"tinyrpc/trace"
 )
 
 // Server RPC调用服务端
 type Server struct {
 	services sync.Map // 所有注册的服务，索引为name 值为实例
+	once     sync.Once
+	health   *Health
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]*connInfo
+	shutdown  int32 // 原子操作，非 0 表示正在关闭
+	inflight  int64 // 原子操作，正在处理的请求数
+
+	interceptors  []Interceptor
+	authenticator auth.Authenticator
+	authorizer    auth.Authorizer
+	provider      metrics.Provider
+	metrics       *serverMetrics
+	tracer        *trace.Tracer
+	logger        logging.Logger
+
+	handleTimeout   time.Duration
+	idleTimeout     time.Duration
+	minPingInterval time.Duration
+	checkOrigin     func(req *http.Request) bool
+	maxRequestSize  int64
+	maxResponseSize int64
+
+	maxConcurrent     int
+	maxConnConcurrent int
+	methodConcurrency map[string]int
+	queueLen          int
+	workers           *workerPool
+	methodLimits      map[string]*concurrencyLimit
+
+	rateLimit        rateConfig
+	methodRateLimits map[string]rateConfig
+	clientRateLimit  rateConfig
+	limiter          *rateLimiter
+}
+
+// DefaultHandleTimeout 没有通过 WithHandleTimeout 配置时每个请求的处理超时
+const DefaultHandleTimeout = time.Second
+
+// NewServer 创建服务端，Server 的零值同样可以直接使用
+func NewServer(opts ...Option) *Server {
+	server := &Server{}
+	for _, opt := range opts {
+		opt(server)
+	}
+	server.init()
+	return server
+}
+
+// init 初始化内部状态并注册内置的 Health 和 Reflection 服务
+func (server *Server) init() {
+	server.once.Do(func() {
+		server.listeners = make(map[net.Listener]struct{})
+		server.conns = make(map[net.Conn]*connInfo)
+		server.health = newHealth()
+		if server.handleTimeout <= 0 {
+			server.handleTimeout = DefaultHandleTimeout
+		}
+		server.metrics = newServerMetrics(server.provider)
+		server.initConcurrency()
+		server.initRateLimit()
+		for _, builtin := range []interface{}{server.health, &Reflection{server: server}} {
+			s := newService(builtin, server.log())
+			s.builtin = true
+			server.services.Store(s.name, s)
+		}
+	})
+}
+
+// Health 返回内置的健康检查服务，用于设置各服务的状态
+func (server *Server) Health() *Health {
+	server.init()
+	return server.health
 }
+
 type Request struct {
+	ctx     context.Context // 连接级别的 ctx，携带认证结果
 	header  *codec.Header
 	argv    reflect.Value
 	reply   reflect.Value
 	service *Service
 	method  *serviceMethod
+	conn    *codec.LimitConn // 用于检查和统计消息大小，可能为 nil
+	size    int64            // 响应写入后为写入的字节数
+	batch   int              // 批量调用的头部中声明的请求数
 }
 
+// Accept 在 lis 上接受连接并为每个连接启动 ServeConn，lis 可以是 tcp、unix 等任意 net.Listener，
+// lis 关闭或 Shutdown 后返回
 func (server *Server) Accept(lis net.Listener) {
+	if !server.trackListener(lis, true) {
+		_ = lis.Close()
+		return
+	}
+	defer server.trackListener(lis, false)
 	for {
 		conn, err := lis.Accept()
 		if err != nil {
-			log.Println("server error: server accept error", err)
+			server.log().Info("server: stop accepting", "addr", lis.Addr().String(), "err", err)
 			return
 		}
 		go server.ServeConn(conn)
 	}
 }
 
+// log 没有通过 WithLogger 配置时使用 logging.Default()
+func (server *Server) log() logging.Logger {
+	if server.logger != nil {
+		return server.logger
+	}
+	return logging.Default()
+}
+
+func (server *Server) isShutdown() bool {
+	return atomic.LoadInt32(&server.shutdown) != 0
+}
+
+// trackListener 记录正在使用的 listener，关闭后返回 false
+func (server *Server) trackListener(lis net.Listener, add bool) bool {
+	server.init()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if add {
+		if server.isShutdown() {
+			return false
+		}
+		server.listeners[lis] = struct{}{}
+	} else {
+		delete(server.listeners, lis)
+	}
+	return true
+}
+
+func (server *Server) trackConn(conn net.Conn, add bool) bool {
+	server.init()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if add {
+		if server.isShutdown() {
+			return false
+		}
+		server.conns[conn] = &connInfo{peer: &Peer{Addr: conn.RemoteAddr()}, since: time.Now()}
+		server.metrics.connections.Add(1)
+	} else if _, ok := server.conns[conn]; ok {
+		delete(server.conns, conn)
+		server.metrics.connections.Add(-1)
+	}
+	return true
+}
+
+// Shutdown 优雅关闭：健康状态置为 NOT_SERVING，停止接受新的连接和请求，
+// 等待正在处理的请求完成或 ctx 结束后关闭所有连接
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.init()
+	server.health.Shutdown()
+	server.mu.Lock()
+	atomic.StoreInt32(&server.shutdown, 1)
+	for lis := range server.listeners {
+		_ = lis.Close()
+	}
+	server.mu.Unlock()
+
+	t := time.NewTicker(10 * time.Millisecond)
+	defer t.Stop()
+	var err error
+	for atomic.LoadInt64(&server.inflight) > 0 && err == nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-t.C:
+		}
+	}
+	server.mu.Lock()
+	for conn := range server.conns {
+		_ = conn.Close()
+	}
+	server.mu.Unlock()
+	return err
+}
+
 // ServeConn serve Connection
 func (server *Server) ServeConn(conn net.Conn) {
 	defer func() {
 		_ = conn.Close()
 	}()
+	if !server.trackConn(conn, true) {
+		return
+	}
+	defer server.trackConn(conn, false)
+	peer, err := newPeer(conn)
+	if err != nil {
+		server.log().Warn("server error: tls handshake", "peer", conn.RemoteAddr().String(), "err", err)
+		return
+	}
+	metered := metrics.MeterConn(conn, server.metrics.bytesIn, server.metrics.bytesOut)
 	var conArgs codec.ConArgs
-	if err := json.NewDecoder(conn).Decode(&conArgs); err != nil {
-		log.Println("server error:decode conArgs error: ", err)
+	dec := json.NewDecoder(metered)
+	if err := dec.Decode(&conArgs); err != nil {
+		server.log().Warn("server error: decode conArgs", "peer", conn.RemoteAddr().String(), "err", err)
 		return
 	}
 	if conArgs.Protocol != "rpc" {
-		log.Println("server error: protocol error: ", conArgs.Protocol)
+		server.log().Warn("server error: unknown protocol", "peer", conn.RemoteAddr().String(), "protocol", conArgs.Protocol)
 		return
 	}
 	f := codec.MakeCodecFuncMap[conArgs.CodecType]
-	c := f(conn) //创建codec 编/译码器
+	if f == nil {
+		server.log().Warn("server error: unknown codec type", "peer", conn.RemoteAddr().String(), "codec", conArgs.CodecType)
+		return
+	}
+	limited := codec.NewLimitConn(newBufferedConn(metered, dec), conArgs.CodecType, server.maxRequestSize)
+	c := f(limited) //创建codec 编/译码器
+	server.updateConn(conn, func(info *connInfo) {
+		info.peer = peer
+		info.codec = conArgs.CodecType
+	})
 
 	send := new(sync.Mutex)
+	connCtx, ok := server.authenticate(context.WithValue(context.Background(), peerKey{}, peer), c, &conArgs, send)
+	if !ok {
+		return
+	}
+	if principal, ok := auth.FromContext(connCtx); ok {
+		server.updateConn(conn, func(info *connInfo) {
+			info.principal = principal.Name
+		})
+	}
+	activity := newConnActivity()
+	if server.idleTimeout > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go server.watchIdle(conn, c, activity, send, done)
+	}
+	dispatch := server.dispatch
+	if connLimit := server.newConnLimit(); connLimit != nil {
+		dispatch = connLimit.submit
+	}
 	group := new(sync.WaitGroup)
+	batch := 0 // 当前批量调用中还未读取的请求数
 	for {
+		limited.Reset()
 		request, err := server.ReadRequest(c)
+		if limited.Exceeded() {
+			server.rejectOversized(c, request, send)
+			break
+		}
+		if request != nil && request.header.Num != 0 && batch > 0 {
+			batch--
+		}
 		if err != nil {
-			if err != io.EOF && request.header != nil {
-				request.header.Error = err
-				request.reply = reflect.ValueOf("error")
-				server.SendResponse(c, request, send)
+			if request == nil {
+				break // header 读取失败，连接已不可用
 			}
-			break
+			server.metrics.reject(request, err)
+			server.sendError(c, request.header, err, send)
+			continue
+		}
+		if request.header.Num == 0 {
+			if batch > 0 {
+				// 批量调用中只能是普通请求
+				server.log().Warn("server error: invalid batch", "peer", conn.RemoteAddr().String(), "method", request.header.ServiceMethod)
+				server.sendError(c, &codec.Header{}, status.New(status.InvalidArgument, "server error: invalid batch"), send)
+				break
+			}
+			if request.header.ServiceMethod == codec.BatchMethod {
+				// 批量调用中的请求与普通请求一样在读取后立即分发，并发处理并分别回复
+				batch = request.batch
+				server.log().Debug("server: read batch", "calls", batch)
+				continue
+			}
+			if !server.handlePing(conn, c, activity, send) {
+				break
+			}
+			continue
+		}
+		if server.isShutdown() {
+			err := status.New(status.Unavailable, "server error: server is shutting down")
+			server.metrics.reject(request, err)
+			server.sendError(c, request.header, err, send)
+			continue
 		}
+		if err := server.allowRate(connCtx, request); err != nil {
+			server.log().Debug("server: rate limited", "method", request.header.ServiceMethod, "err", err)
+			server.metrics.reject(request, err)
+			server.sendError(c, request.header, err, send)
+			continue
+		}
+		request.ctx = connCtx
+		request.conn = limited
+		server.metrics.reqSize.Observe(float64(limited.ReadSize()), request.service.name, request.method.method.Name)
+		atomic.AddInt64(&server.inflight, 1)
 		group.Add(1)
-		go server.HandleRequest(c, request, send, group, time.Second)
+		activity.begin()
+		submit := dispatch
+		if request.service.builtin {
+			// 内置服务不经过并发限制和 worker 池，过载时健康检查仍能得到回复
+			submit = func(t task) { go t.run() }
+		}
+		submit(task{
+			serviceMethod: request.header.ServiceMethod,
+			run: func() {
+				server.HandleRequest(c, request, send, group, server.handleTimeout)
+				activity.end()
+			},
+			reject: func(err error) {
+				server.log().Debug("server: reject request", "method", request.header.ServiceMethod, "err", err)
+				server.metrics.reject(request, err)
+				server.sendError(c, request.header, err, send)
+				atomic.AddInt64(&server.inflight, -1)
+				group.Done()
+				activity.end()
+			},
+		})
 	}
 	group.Wait()
 }
+
+// authenticate 校验握手时提交的凭证，通过后返回带有调用方信息的连接级 ctx。
+// 客户端提交了凭证或服务端要求认证时，用 Num 为 0 的 header 返回认证结果，失败后关闭连接。
+// 没有设置 Authenticator 时，mTLS 验证过的客户端证书作为调用方
+func (server *Server) authenticate(ctx context.Context, c codec.Codec, conArgs *codec.ConArgs, send *sync.Mutex) (context.Context, bool) {
+	if peer, _ := PeerFromContext(ctx); server.authenticator == nil && peer.TLS != nil && len(peer.TLS.VerifiedChains) > 0 {
+		ctx = auth.NewContext(ctx, auth.PrincipalFromCertificate(peer.TLS.PeerCertificates[0]))
+	}
+	if server.authenticator == nil && conArgs.Credentials == nil {
+		return ctx, true
+	}
+	header := &codec.Header{}
+	if server.authenticator != nil {
+		principal, err := server.authenticator.Authenticate(conArgs.Credentials)
+		if err != nil {
+			server.log().Warn("server error: authenticate", "err", err)
+			server.sendError(c, header, status.Errorf(status.Unauthenticated, "server error: %v", err), send)
+			return nil, false
+		}
+		ctx = auth.NewContext(ctx, principal)
+	}
+	server.SendResponse(c, &Request{header: header, reply: reflect.ValueOf("ok")}, send)
+	return ctx, true
+}
+
+// authorize 检查 ctx 中的调用方是否有权限调用该方法
+func (server *Server) authorize(ctx context.Context, info *RequestInfo) error {
+	if server.authorizer == nil {
+		return nil
+	}
+	principal, _ := auth.FromContext(ctx)
+	if err := server.authorizer.Authorize(principal, info.ServiceMethod); err != nil {
+		server.log().Warn("server error: authorize", "method", info.ServiceMethod, "err", err)
+		return status.Errorf(status.PermissionDenied, "server error: %v", err)
+	}
+	return nil
+}
+
+// sendError 返回错误响应，body 为占位的字符串
+func (server *Server) sendError(c codec.Codec, header *codec.Header, err error, send *sync.Mutex) {
+	response := &Request{
+		header: &codec.Header{
+			Num:           header.Num,
+			ServiceMethod: header.ServiceMethod,
+			Error:         err.Error(),
+			Code:          uint32(status.CodeOf(err)),
+			RetryAfter:    retryAfterMillis(err),
+		},
+		reply: reflect.ValueOf("error"),
+	}
+	server.SendResponse(c, response, send)
+}
+
+// bufferedConn json 解码握手时可能多读了后续的数据，需要先从缓冲中读取
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func newBufferedConn(conn net.Conn, dec *json.Decoder) *bufferedConn {
+	buffered, _ := io.ReadAll(dec.Buffered())
+	// json.Encoder 会在握手参数后写入换行符
+	if len(buffered) > 0 && buffered[0] == '\n' {
+		buffered = buffered[1:]
+	}
+	return &bufferedConn{Conn: conn, r: io.MultiReader(bytes.NewReader(buffered), conn)}
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
 func (server *Server) Register(serviceValue interface{}) error {
-	s := NewService(serviceValue)
+	server.init()
+	s := newService(serviceValue, server.log())
 	if _, loaded := server.services.LoadOrStore(s.name, s); loaded {
-		log.Println("server error: service has been loaded", s.name)
+		server.log().Error("server error: service has been loaded", "service", s.name)
 		return errors.New("server error: service has been loaded" + s.name)
 	}
-	log.Println(s.name + "register successfully")
+	server.health.SetServingStatus(s.name, StatusServing)
+	server.log().Info("server: register service", "service", s.name)
 	return nil
 }
 func (server *Server) findServiceAndMethod(serviceMethod string) (s *Service, m *serviceMethod) {
-	str := strings.Split(serviceMethod, ".")
-	serviceName := str[0]
-	methodName := str[1]
-	log.Printf("find service %s method %s\n", serviceName, methodName)
+	server.init()
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		server.log().Debug("server error: ill-formed service method", "method", serviceMethod)
+		return
+	}
+	serviceName := serviceMethod[:dot]
+	methodName := serviceMethod[dot+1:]
+	server.log().Debug("server: find service method", "service", serviceName, "method", methodName)
 	sv, ok := server.services.Load(serviceName)
 	if !ok {
-		log.Println("server error: can`t find service", serviceName)
+		server.log().Debug("server error: can`t find service", "service", serviceName)
 		return
 	}
 	s = sv.(*Service)
 	m = s.method[methodName]
 	if m == nil {
-		log.Println("server error: can`t find method", methodName)
+		server.log().Debug("server error: can`t find method", "service", serviceName, "method", methodName)
 		return
 	}
 	return
 }
 func (server *Server) ReadRequest(c codec.Codec) (*Request, error) {
 	var header codec.Header
-	log.Println("------------server read request------------")
 	if err := c.ReadHeader(&header); err != nil {
 		if err != io.EOF { //EOF代表读完，不应该输出error
-			log.Println("server error: read request header", err, header.ServiceMethod, header.Num)
+			server.log().Warn("server error: read request header", "err", err)
 		}
 		return nil, err
 	}
 	request := &Request{
 		header: &header,
 	}
+	// ping 不对应服务方法，由 ServeConn 回复
+	if header.Num == 0 && header.ServiceMethod == codec.PingMethod {
+		if err := c.ReadBody(nil); err != nil {
+			return nil, err
+		}
+		return request, nil
+	}
+	if header.Num == 0 && header.ServiceMethod == codec.BatchMethod {
+		if err := c.ReadBody(&request.batch); err != nil {
+			return nil, err
+		}
+		if request.batch < 0 {
+			return request, status.New(status.InvalidArgument, "server error: invalid batch size")
+		}
+		return request, nil
+	}
 	//request.argv = reflect.New(reflect.TypeOf(""))
 
 	request.service, request.method = server.findServiceAndMethod(header.ServiceMethod)
 	if request.service == nil || request.method == nil {
-		return nil, errors.New("server error: can't init request")
+		// 丢弃 body，保证后续请求可以继续读取
+		_ = c.ReadBody(nil)
+		return request, status.New(status.NotFound, "server error: can't find service method "+header.ServiceMethod)
 	}
 
 	request.argv = request.method.newArgv()
 	request.reply = request.method.newReply()
 
-	if err := c.ReadBody(request.argv.Interface()); err != nil {
-		log.Println("server error: read request argv", err)
-		return nil, err
+	argvi := request.argv.Interface()
+	if request.argv.Type().Kind() != reflect.Ptr {
+		argvi = request.argv.Addr().Interface()
 	}
-	log.Println("server decode request successfully", request.header, request.argv.Elem())
+	if err := c.ReadBody(argvi); err != nil {
+		server.log().Warn("server error: read request argv", "method", header.ServiceMethod, "num", header.Num, "err", err)
+		return request, status.New(status.InvalidArgument, "server error: read request argv: "+err.Error())
+	}
+	server.log().Debug("server: read request", "method", header.ServiceMethod, "num", header.Num)
 	return request, nil
 }
+
+// HandleRequest 处理请求并回复。handler 超时后立即回复超时错误，但要等 handler 真正返回后才返回，
+// 使 worker 和并发限制的名额一直被占用，忽略 ctx 的 handler 不会让协程无限增长
 func (server *Server) HandleRequest(c codec.Codec, request *Request, send *sync.Mutex, group *sync.WaitGroup, timeout time.Duration) {
-	defer group.Done()
-	log.Println("------------server handle request------------")
+	ctx := request.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ctx = metadata.NewIncomingContext(ctx, request.header.Metadata)
+	t := &trailer{}
+	ctx = context.WithValue(ctx, trailerKey{}, t)
+	info := &RequestInfo{
+		ServiceMethod: request.header.ServiceMethod,
+		Service:       request.service.name,
+		Method:        request.method.method.Name,
+	}
+	stats := &request.method.stats
+	stats.begin()
+	start := time.Now()
+	observe := server.metrics.begin(info)
+	ctx, span := server.startSpan(ctx, info)
+	handler := server.chain(info, func(ctx context.Context, argv interface{}, reply interface{}) error {
+		return request.service.call(ctx, request.method, request.argv, request.reply)
+	})
 
-	//replyString := "rpc response your num " + strconv.Itoa(int(request.header.Num))
-	//request.reply = reflect.ValueOf(replyString)
-	called := make(chan struct{})
+	called := make(chan error, 1)
 	go func() {
-		err := request.service.call(request.method, request.argv, request.reply)
-		if err != nil {
-			request.header.Error = err
+		// 在所有拦截器之前检查权限，直接返回结果的拦截器（例如缓存）也不会为没有权限的调用方服务
+		err := server.authorize(ctx, info)
+		if err == nil {
+			err = handler(ctx, request.argv.Interface(), request.reply.Interface())
 		}
-		called <- struct{}{}
+		called <- err
 	}()
+	response := &Request{header: &codec.Header{Num: request.header.Num, ServiceMethod: request.header.ServiceMethod}, reply: request.reply, conn: request.conn}
+	timedOut := false
 	select {
-	case <-time.After(timeout):
-		request.header.Error = errors.New("server error: handle request timeout")
-	case <-called:
+	case <-ctx.Done():
+		// handler 仍可能在修改 reply，不能再编码它
+		timedOut = true
+		response.header.Error = "server error: handle request timeout"
+		response.header.Code = uint32(status.DeadlineExceeded)
+		response.reply = reflect.ValueOf("error")
+	case err := <-called:
+		if err != nil {
+			response.header.Error = err.Error()
+			response.header.Code = uint32(status.CodeOf(err))
+			response.header.RetryAfter = retryAfterMillis(err)
+		}
+	}
+	// 响应超过大小限制时 SendResponse 会改为返回错误，之后再记录调用的结果
+	response.header.Metadata = t.get()
+	server.SendResponse(c, response, send)
+	stats.end(time.Since(start), response.header.Error != "")
+	observe(status.Code(response.header.Code))
+	if span != nil {
+		span.End(responseError(response.header))
+	}
+	if response.conn != nil {
+		server.metrics.respSize.Observe(float64(response.size), info.Service, info.Method)
+	}
+	// 已经回复，不再计入正在处理的请求，Shutdown 和连接关闭不需要等待超时的 handler
+	atomic.AddInt64(&server.inflight, -1)
+	group.Done()
+	if timedOut {
+		<-called
 	}
-	server.SendResponse(c, request, send)
 }
 func (server *Server) SendResponse(c codec.Codec, request *Request, send *sync.Mutex) {
 	send.Lock()
 	defer send.Unlock()
+	conn := request.conn
+	if conn == nil {
+		server.writeResponse(c, request)
+		return
+	}
+	// 先缓存编码后的响应，超过大小限制时丢弃并改为返回错误，连接可以继续使用
+	conn.Hold()
+	conn.BeginMessage(server.maxResponseSize)
+	server.writeResponse(c, request)
+	size, err := conn.EndMessage()
+	if errors.Is(err, codec.ErrMessageTooLarge) {
+		server.log().Warn("server error: response too large", "method", request.header.ServiceMethod, "limit", server.maxResponseSize)
+		err := status.Errorf(status.ResourceExhausted, "server error: response larger than %d bytes", server.maxResponseSize)
+		request.header.Error = err.Error()
+		request.header.Code = uint32(status.ResourceExhausted)
+		request.reply = reflect.ValueOf("error")
+		server.writeResponse(c, request)
+		size, _ = conn.EndMessage()
+	}
+	if err := conn.FlushEach(); err != nil {
+		server.log().Warn("server error: write response", "err", err)
+	}
+	request.size = size
+}
+
+func (server *Server) writeResponse(c codec.Codec, request *Request) {
 	if err := c.WriteHeader(*request.header); err != nil {
-		log.Println("server error:write header ", err)
+		server.log().Warn("server error: write header", "err", err)
 	}
 	if err := c.WriteBody(request.reply.Interface()); err != nil {
-		log.Println("server error:write body ", err)
+		server.log().Warn("server error: write body", "err", err)
 	}
 }
 
@@ -164,27 +610,61 @@ var defaultServer = &Server{}
 func Accept(lis net.Listener) {
 	defaultServer.Accept(lis)
 }
+func Shutdown(ctx context.Context) error {
+	return defaultServer.Shutdown(ctx)
+}
 func Register(serviceValue interface{}) error {
 	return defaultServer.Register(serviceValue)
 }
 func SendHeartbeat(registryAddr string, addr string) error {
-	httpClient := &http.Client{}
+	return SendHeartbeatTo([]string{registryAddr}, addr)
+}
+
+// SendHeartbeatTo 依次尝试多个注册中心，任意一个成功即返回
+func SendHeartbeatTo(registries []string, addr string) error {
+	return updateRegistry(registries, "POST", addr)
+}
 
-	req, _ := http.NewRequest("POST", registryAddr, nil)
-	req.Header.Set("server", addr)
-	if _, err := httpClient.Do(req); err != nil {
-		log.Println("server error: heart beat err:", err)
-		return err
+// Deregister 从注册中心注销服务端
+func Deregister(registries []string, addr string) error {
+	return updateRegistry(registries, "DELETE", addr)
+}
+
+func updateRegistry(registries []string, method string, addr string) error {
+	httpClient := &http.Client{Timeout: time.Second}
+	err := errors.New("server error: no registry configured")
+	for _, registryAddr := range registries {
+		req, _ := http.NewRequest(method, registryAddr, nil)
+		req.Header.Set("server", addr)
+		resp, e := httpClient.Do(req)
+		if e != nil {
+			logging.Default().Warn("server error: heartbeat", "registry", registryAddr, "err", e)
+			err = e
+			continue
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			logging.Default().Warn("server error: heartbeat", "registry", registryAddr, "status", resp.Status)
+			err = errors.New("server error: registry " + registryAddr + " " + resp.Status)
+			continue
+		}
+		logging.Default().Debug("server: send heartbeat", "addr", addr, "registry", registryAddr)
+		return nil
 	}
-	log.Printf("server %v send heartbeat\n", addr)
-	return nil
+	return err
 }
 func ToSendHeartbeat(registryAddr string, addr string, timeout time.Duration) error {
+	return ToSendHeartbeatTo([]string{registryAddr}, addr, timeout)
+}
+
+// ToSendHeartbeatTo 定时向注册中心集群发送心跳，直到所有注册中心都不可用
+func ToSendHeartbeatTo(registries []string, addr string, timeout time.Duration) error {
 	t := time.NewTicker(timeout)
+	defer t.Stop()
 	var err error
 	for err == nil {
 		<-t.C
-		err = SendHeartbeat(registryAddr, addr)
+		err = SendHeartbeatTo(registries, addr)
 	}
-	return nil
+	return err
 }
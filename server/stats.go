@@ -0,0 +1,81 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWindow 每个方法保留最近多少次调用的耗时用于计算分位数
+const latencyWindow = 1024
+
+// methodStats 单个方法的调用统计
+type methodStats struct {
+	mu        sync.Mutex
+	calls     uint64
+	errors    uint64
+	inflight  int64
+	latencies [latencyWindow]time.Duration
+	next      int // 下一次写入 latencies 的位置
+}
+
+// MethodStats 方法调用统计的快照，分位数基于最近 1024 次调用
+type MethodStats struct {
+	Calls    uint64
+	Errors   uint64
+	InFlight int64
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+}
+
+func (s *methodStats) begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight++
+}
+
+func (s *methodStats) end(latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight--
+	s.calls++
+	if failed {
+		s.errors++
+	}
+	s.latencies[s.next%latencyWindow] = latency
+	s.next++
+}
+
+func (s *methodStats) snapshot() MethodStats {
+	s.mu.Lock()
+	n := s.next
+	if n > latencyWindow {
+		n = latencyWindow
+	}
+	latencies := make([]time.Duration, n)
+	copy(latencies, s.latencies[:n])
+	stats := MethodStats{Calls: s.calls, Errors: s.errors, InFlight: s.inflight}
+	s.mu.Unlock()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.P50 = percentile(latencies, 0.50)
+	stats.P90 = percentile(latencies, 0.90)
+	stats.P99 = percentile(latencies, 0.99)
+	return stats
+}
+
+// percentile sorted 需要已经排好序
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMethodStats(t *testing.T) {
+	var s methodStats
+	for i := 1; i <= 100; i++ {
+		s.begin()
+		s.end(time.Duration(i)*time.Millisecond, i%10 == 0)
+	}
+	s.begin()
+	got := s.snapshot()
+	if got.Calls != 100 || got.Errors != 10 || got.InFlight != 1 {
+		t.Fatalf("stats %+v", got)
+	}
+	if got.P50 != 50*time.Millisecond || got.P90 != 90*time.Millisecond || got.P99 != 99*time.Millisecond {
+		t.Fatalf("percentiles %v %v %v", got.P50, got.P90, got.P99)
+	}
+
+	// 超过窗口大小后只统计最近的调用
+	for i := 0; i < latencyWindow; i++ {
+		s.end(time.Second, false)
+	}
+	if got := s.snapshot(); got.P50 != time.Second {
+		t.Fatalf("p50 %v after window is full", got.P50)
+	}
+}
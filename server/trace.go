@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"tinyrpc/codec"
+	"tinyrpc/metadata"
+	"tinyrpc/status"
+	"tinyrpc/trace"
+)
+
+// startSpan 没有设置 Tracer 时返回 nil
+func (server *Server) startSpan(ctx context.Context, info *RequestInfo) (context.Context, *trace.Span) {
+	if server.tracer == nil {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if sc, ok := trace.ParseTraceparent(md.Get(trace.MetadataKey)); ok {
+		ctx = trace.ContextWithRemoteParent(ctx, sc)
+	}
+	ctx, span := server.tracer.Start(ctx, info.ServiceMethod, trace.KindServer)
+	span.SetAttribute("rpc.method", info.ServiceMethod)
+	if peer, ok := PeerFromContext(ctx); ok {
+		span.SetAttribute("net.peer.address", peer.Addr.String())
+	}
+	return ctx, span
+}
+
+// responseError 将响应 header 中的错误还原为带错误码的错误
+func responseError(header *codec.Header) error {
+	if header.Error == "" {
+		return nil
+	}
+	return status.New(status.Code(header.Code), header.Error)
+}
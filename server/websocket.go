@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+	"tinyrpc/codec"
+	"tinyrpc/websocket"
+)
+
+// DefaultWebSocketPath Mount 挂载 WebSocket 入口的路径，见 codec.DefaultWebSocketPath
+const DefaultWebSocketPath = codec.DefaultWebSocketPath
+
+// WebSocketHandler 接受 WebSocket 连接，升级后与普通连接的协议相同：
+// 第一条消息为握手参数，之后每个 header 和 body 各为一条消息。
+// 浏览器等非 Go 客户端需要在握手参数中指定 json 编解码。默认只接受同源的浏览器请求，见 WithCheckOrigin
+func (server *Server) WebSocketHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := websocket.Upgrade(w, req, server.checkOrigin)
+		if err != nil {
+			server.log().Warn("server error: websocket upgrade", "peer", req.RemoteAddr, "err", err)
+			return
+		}
+		server.ServeConn(conn)
+	})
+}
@@ -0,0 +1,106 @@
+/*
+status 调用失败时的错误码，随响应 header 返回给客户端，
+客户端通过 CodeOf 判断错误类型，例如是否需要重试
+*/
+package status
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Code uint32
+
+const (
+	OK Code = iota
+	Canceled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	PermissionDenied
+	ResourceExhausted
+	Unavailable
+	Unauthenticated
+	Internal
+	// RateLimited 调用超过了服务端的限流配置，可以在 RetryAfterOf 返回的时间后重试
+	RateLimited
+)
+
+var codeNames = map[Code]string{
+	OK:                "OK",
+	Canceled:          "Canceled",
+	Unknown:           "Unknown",
+	InvalidArgument:   "InvalidArgument",
+	DeadlineExceeded:  "DeadlineExceeded",
+	NotFound:          "NotFound",
+	PermissionDenied:  "PermissionDenied",
+	ResourceExhausted: "ResourceExhausted",
+	Unavailable:       "Unavailable",
+	Unauthenticated:   "Unauthenticated",
+	Internal:          "Internal",
+	RateLimited:       "RateLimited",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Code(%d)", uint32(c))
+}
+
+// Error 带错误码的错误，Error() 只返回错误信息，保证经过网络传输后错误信息不变
+type Error struct {
+	Code    Code
+	Message string
+	// RetryAfter 服务端建议的重试等待时间，为 0 时没有建议
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// WithRetryAfter 返回带有重试等待时间的副本
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	copied := *e
+	copied.RetryAfter = d
+	return &copied
+}
+
+func Errorf(code Code, format string, args ...interface{}) error {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+// FromError 取出 err 链中的 *Error
+func FromError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// CodeOf err 为 nil 时返回 OK，不带错误码的错误返回 Unknown
+func CodeOf(err error) Code {
+	if err == nil {
+		return OK
+	}
+	if e, ok := FromError(err); ok {
+		return e.Code
+	}
+	return Unknown
+}
+
+// RetryAfterOf 返回 err 中服务端建议的重试等待时间，没有时返回 0
+func RetryAfterOf(err error) time.Duration {
+	if e, ok := FromError(err); ok {
+		return e.RetryAfter
+	}
+	return 0
+}
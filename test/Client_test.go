@@ -12,12 +12,12 @@ import (
 )
 
 func TestClient(t *testing.T) {
-	lis, _ := net.Listen("tcp", ":5000")
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
 	go func() {
 		server.Accept(lis)
 	}()
 
-	c, err := client.Dial("tcp", ":5000")
+	c, err := client.Dial("tcp", lis.Addr().String())
 	if err != nil {
 		log.Println("Dial失败")
 		return
@@ -32,7 +32,8 @@ func TestClient(t *testing.T) {
 		go func() {
 			defer group.Done()
 			var reply string
-			ctx, _ := context.WithTimeout(context.Background(), time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
 			if err := c.Call(ctx, "Hello.World", "wwb", &reply); err != nil {
 				log.Println("call error:", err)
 				return
@@ -11,10 +11,10 @@ import (
 )
 
 func TestServer(t *testing.T) {
-	lis, _ := net.Listen("tcp", ":5000")
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
 	go server.Accept(lis)
 	log.Println("server 启动")
-	conn, _ := net.Dial("tcp", ":5000")
+	conn, _ := net.Dial("tcp", lis.Addr().String())
 	defer func() { _ = conn.Close() }()
 
 	c := codec.MakeGobCodecFunc(conn)
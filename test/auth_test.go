@@ -0,0 +1,159 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/client"
+	"tinyrpc/server"
+	"tinyrpc/status"
+)
+
+type WhoAmI struct{}
+
+// Name 返回认证通过的调用方
+func (w *WhoAmI) Name(ctx context.Context, argv string, reply *string) error {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return status.New(status.Unauthenticated, "no principal")
+	}
+	*reply = p.Name
+	return nil
+}
+
+func TestBearerAuth(t *testing.T) {
+	var seen *auth.Principal
+	interceptor := func(ctx context.Context, info *server.RequestInfo, argv interface{}, reply interface{}, next server.Handler) error {
+		seen, _ = auth.FromContext(ctx)
+		return next(ctx, argv, reply)
+	}
+	_, addr := startServer(t, []server.Option{
+		server.WithAuthenticator(auth.TokenAuthenticator{"secret": {Name: "alice", Roles: []string{"admin"}}}),
+		server.WithInterceptor(interceptor),
+	}, &WhoAmI{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c, err := client.Dial("tcp", addr, client.WithCredentials(auth.BearerToken("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	var reply string
+	if err := c.Call(ctx, "WhoAmI.Name", "", &reply); err != nil || reply != "alice" {
+		t.Fatalf("reply %q err %v", reply, err)
+	}
+	if seen == nil || !seen.HasRole("admin") {
+		t.Fatalf("interceptor saw principal %v", seen)
+	}
+
+	if _, err := client.Dial("tcp", addr, client.WithCredentials(auth.BearerToken("wrong"))); status.CodeOf(err) != status.Unauthenticated {
+		t.Fatalf("dial with wrong token: %v", err)
+	}
+
+	// 未提交凭证时，第一次调用返回认证失败，之后连接不可用
+	c2, err := client.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c2.Close() }()
+	if err := c2.Call(ctx, "WhoAmI.Name", "", &reply); status.CodeOf(err) != status.Unauthenticated {
+		t.Fatalf("call without credentials: %v", err)
+	}
+	if c2.IsAvailable() {
+		t.Fatal("connection should be closed after authentication failed")
+	}
+	if err := c2.Call(ctx, "WhoAmI.Name", "", &reply); status.CodeOf(err) != status.Unauthenticated {
+		t.Fatalf("call after authentication failed: %v", err)
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	secret := []byte("shared-secret")
+	_, addr := startServer(t, []server.Option{server.WithAuthenticator(auth.NewHMACAuthenticator(map[string]auth.HMACKey{
+		"billing": {Secret: secret, Principal: &auth.Principal{Name: "billing"}},
+	}, 0))}, &WhoAmI{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c, err := client.Dial("tcp", addr, client.WithCredentials(auth.HMAC("billing", secret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	var reply string
+	if err := c.Call(ctx, "WhoAmI.Name", "", &reply); err != nil || reply != "billing" {
+		t.Fatalf("reply %q err %v", reply, err)
+	}
+
+	if _, err := client.Dial("tcp", addr, client.WithCredentials(auth.HMAC("billing", []byte("wrong")))); status.CodeOf(err) != status.Unauthenticated {
+		t.Fatalf("dial with wrong secret: %v", err)
+	}
+}
+
+func TestCredentialsWithoutAuthenticator(t *testing.T) {
+	_, addr := startServer(t, nil, &WhoAmI{})
+	c, err := client.Dial("tcp", addr, client.WithCredentials(auth.BearerToken("unused")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply string
+	if err := c.Call(ctx, "WhoAmI.Name", "", &reply); status.CodeOf(err) != status.Unauthenticated {
+		t.Fatalf("call without authenticator: %v", err)
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	acl, err := auth.NewACL(&auth.Policy{Rules: []auth.Rule{
+		{Effect: auth.Allow, Roles: []string{"admin"}, Methods: []string{"WhoAmI.*"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	interceptor := func(ctx context.Context, info *server.RequestInfo, argv interface{}, reply interface{}, next server.Handler) error {
+		called = true
+		return next(ctx, argv, reply)
+	}
+	_, addr := startServer(t, []server.Option{
+		server.WithAuthenticator(auth.TokenAuthenticator{
+			"admin-token": {Name: "alice", Roles: []string{"admin"}},
+			"user-token":  {Name: "bob"},
+		}),
+		server.WithAuthorizer(acl),
+		server.WithInterceptor(interceptor),
+	}, &WhoAmI{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	admin, err := client.Dial("tcp", addr, client.WithCredentials(auth.BearerToken("admin-token")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = admin.Close() }()
+	var reply string
+	if err := admin.Call(ctx, "WhoAmI.Name", "", &reply); err != nil || reply != "alice" {
+		t.Fatalf("reply %q err %v", reply, err)
+	}
+
+	user, err := client.Dial("tcp", addr, client.WithCredentials(auth.BearerToken("user-token")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = user.Close() }()
+	called = false
+	if err := user.Call(ctx, "WhoAmI.Name", "", &reply); status.CodeOf(err) != status.PermissionDenied {
+		t.Fatalf("call without permission: %v", err)
+	}
+	if called {
+		t.Fatal("interceptors should not run for calls denied by authorization")
+	}
+	// 权限不足不影响连接上的其他调用
+	if !user.IsAvailable() {
+		t.Fatal("connection closed after permission denied")
+	}
+}
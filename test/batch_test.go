@@ -0,0 +1,139 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/codec"
+	"tinyrpc/status"
+)
+
+func TestBatch(t *testing.T) {
+	_, addr := startServer(t, nil, &TestAdd{}, &Sleeper{})
+	for _, codecType := range []codec.Type{codec.GobType, codec.JsonType} {
+		c, err := client.Dial("tcp", addr, client.WithCodec(codecType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := c.NewBatch()
+		replies := make([]Reply, 100)
+		calls := make([]*client.Call, len(replies))
+		for i := range replies {
+			calls[i] = batch.Add("TestAdd.Add", &Argv{A: i, B: 1}, &replies[i], nil)
+		}
+		missing := batch.Add("TestAdd.Missing", &Argv{}, &Reply{}, nil)
+		if batch.Len() != len(replies)+1 {
+			t.Fatalf("%s: unexpected batch size %d", codecType, batch.Len())
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := batch.Do(ctx); status.CodeOf(err) != status.NotFound {
+			t.Fatalf("%s: expected the NotFound error of the failed call, got %v", codecType, err)
+		}
+		cancel()
+		for i, call := range calls {
+			if call.Error != nil || replies[i].C != i+1 {
+				t.Fatalf("%s: call %d: reply %d err %v", codecType, i, replies[i].C, call.Error)
+			}
+		}
+		if status.CodeOf(missing.Error) != status.NotFound {
+			t.Fatalf("%s: expected NotFound, got %v", codecType, missing.Error)
+		}
+		if batch.Len() != 0 {
+			t.Fatalf("%s: batch should be empty after sending", codecType)
+		}
+		// 批量调用之后连接可以继续使用
+		var reply Reply
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+			t.Fatalf("%s: reply %d err %v", codecType, reply.C, err)
+		}
+		cancel()
+		_ = c.Close()
+	}
+}
+
+func TestBatchConcurrentDispatch(t *testing.T) {
+	_, addr := startServer(t, nil, &TestAdd{}, &Sleeper{})
+	c := dialServer(t, addr)
+	batch := c.NewBatch()
+	done := make(chan *client.Call, 5)
+	ms := 200
+	for i := 0; i < 5; i++ {
+		batch.Add("Sleeper.Sleep", &ms, new(int), done)
+	}
+	start := time.Now()
+	batch.Send()
+	for i := 0; i < 5; i++ {
+		if call := <-done; call.Error != nil {
+			t.Fatal(call.Error)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Duration(ms)*time.Millisecond {
+		t.Fatalf("calls in a batch should be handled concurrently, took %v", elapsed)
+	}
+}
+
+func TestBatchTimeoutAndPendingLimit(t *testing.T) {
+	_, addr := startServer(t, nil, &TestAdd{}, &Sleeper{})
+	c, err := client.Dial("tcp", addr, client.WithMaxPendingCalls(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	batch := c.NewBatch()
+	ms := 300
+	calls := make([]*client.Call, 3)
+	for i := range calls {
+		calls[i] = batch.Add("Sleeper.Sleep", &ms, new(int), nil)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// 超过待回复上限的调用直接失败，其他调用等待到 ctx 结束
+	if err := batch.Do(ctx); status.CodeOf(err) != status.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	<-calls[2].Done
+	if status.CodeOf(calls[2].Error) != status.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", calls[2].Error)
+	}
+	if n := c.PendingCalls(); n != 0 {
+		t.Fatalf("timed out calls should be removed, got %d pending", n)
+	}
+}
+
+func TestBatchDoDeliversToDone(t *testing.T) {
+	_, addr := startServer(t, nil, &TestAdd{}, &Sleeper{})
+	c := dialServer(t, addr)
+	batch := c.NewBatch()
+	done := make(chan *client.Call, 3)
+	fast, slow := 10, 500
+	batch.Add("Sleeper.Sleep", &fast, new(int), done)
+	batch.Add("Sleeper.Sleep", &slow, new(int), done)
+	batch.Add("Sleeper.Sleep", &slow, new(int), done)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := batch.Do(ctx); status.CodeOf(err) != status.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	// Do 不读取调用方的 done，超时的调用同样会发送到 done
+	codes := make(map[status.Code]int)
+	for i := 0; i < 3; i++ {
+		select {
+		case call := <-done:
+			codes[status.CodeOf(call.Error)]++
+		case <-time.After(time.Second):
+			t.Fatalf("only %d calls delivered to done", i)
+		}
+	}
+	if codes[status.OK] != 1 || codes[status.DeadlineExceeded] != 2 {
+		t.Fatalf("expected 1 OK and 2 DeadlineExceeded, got %v", codes)
+	}
+	// 超时的调用的回复到达后被丢弃，不会再次发送
+	time.Sleep(time.Duration(slow) * time.Millisecond)
+	select {
+	case call := <-done:
+		t.Fatalf("call %d delivered twice", call.Num)
+	default:
+	}
+}
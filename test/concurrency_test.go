@@ -0,0 +1,141 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+	"tinyrpc/status"
+)
+
+// sleepCalls 依次发起 n 个 Sleeper.Sleep 调用，按状态码统计结果
+func sleepCalls(t *testing.T, c *client.Client, n int, ms int) map[status.Code]int {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	calls := make([]*client.Call, n)
+	for i := range calls {
+		arg, reply := ms, 0
+		calls[i] = c.GoContext(ctx, "Sleeper.Sleep", &arg, &reply, nil)
+		// 保证请求按顺序到达服务端
+		time.Sleep(5 * time.Millisecond)
+	}
+	codes := make(map[status.Code]int)
+	for _, call := range calls {
+		<-call.Done
+		if call.Error == nil && *call.Reply.(*int) != ms {
+			t.Fatalf("unexpected reply %d", *call.Reply.(*int))
+		}
+		codes[status.CodeOf(call.Error)]++
+	}
+	return codes
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	_, addr := startServer(t, []server.Option{
+		server.WithMaxConcurrentRequests(2),
+		server.WithQueueSize(2),
+	}, &Sleeper{})
+	c := dialServer(t, addr)
+	codes := sleepCalls(t, c, 6, 200)
+	if codes[status.OK] != 4 || codes[status.Unavailable] != 2 {
+		t.Fatalf("expected 4 OK and 2 Unavailable, got %v", codes)
+	}
+	// 队列清空后可以继续处理请求
+	if codes := sleepCalls(t, c, 2, 10); codes[status.OK] != 2 {
+		t.Fatalf("expected 2 OK after the burst, got %v", codes)
+	}
+}
+
+func TestMaxConnConcurrentRequests(t *testing.T) {
+	_, addr := startServer(t, []server.Option{
+		server.WithMaxConnConcurrentRequests(1),
+		server.WithQueueSize(-1),
+	}, &Sleeper{})
+	c1 := dialServer(t, addr)
+	c2 := dialServer(t, addr)
+
+	done := make(chan map[status.Code]int)
+	go func() { done <- sleepCalls(t, c1, 2, 200) }()
+	time.Sleep(50 * time.Millisecond)
+	// 限制只作用于单个连接，其他连接不受影响
+	if codes := sleepCalls(t, c2, 1, 10); codes[status.OK] != 1 {
+		t.Fatalf("other connection should not be limited, got %v", codes)
+	}
+	if codes := <-done; codes[status.OK] != 1 || codes[status.ResourceExhausted] != 1 {
+		t.Fatalf("expected 1 OK and 1 ResourceExhausted, got %v", codes)
+	}
+}
+
+func TestMethodConcurrency(t *testing.T) {
+	_, addr := startServer(t, []server.Option{
+		server.WithMethodConcurrency("Sleeper.Sleep", 1),
+		server.WithQueueSize(1),
+	}, &TestAdd{}, &Sleeper{})
+	c := dialServer(t, addr)
+
+	done := make(chan map[status.Code]int)
+	go func() { done <- sleepCalls(t, c, 3, 200) }()
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("other methods should not be limited: reply %d err %v", reply.C, err)
+	}
+	if codes := <-done; codes[status.OK] != 2 || codes[status.ResourceExhausted] != 1 {
+		t.Fatalf("expected 2 OK and 1 ResourceExhausted, got %v", codes)
+	}
+}
+
+func TestTimeoutHoldsWorker(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithMaxConcurrentRequests(1)}, &Sleeper{})
+	c := dialServer(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Sleeper.Sleep 不接收 ctx，超过处理超时后仍然立即收到超时错误
+	start := time.Now()
+	slow, reply := 1500, 0
+	if err := c.Call(ctx, "Sleeper.Sleep", &slow, &reply); status.CodeOf(err) != status.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 1400*time.Millisecond {
+		t.Fatalf("timeout reply took %v", elapsed)
+	}
+	// 超时的 handler 返回前仍然占用 worker，下一个请求需要等待
+	fast := 10
+	if err := c.Call(ctx, "Sleeper.Sleep", &fast, &reply); err != nil || reply != fast {
+		t.Fatalf("reply %d err %v", reply, err)
+	}
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Fatalf("worker released before the handler returned, next call finished after %v", elapsed)
+	}
+}
+
+func TestConcurrencyLimitsExemptBuiltinServices(t *testing.T) {
+	_, addr := startServer(t, []server.Option{
+		server.WithMaxConcurrentRequests(1),
+		server.WithMaxConnConcurrentRequests(1),
+		server.WithMethodConcurrency("Health.Check", 1),
+		server.WithQueueSize(-1),
+	}, &Sleeper{})
+	c := dialServer(t, addr)
+
+	// 占满 worker 池和连接的并发限制，之后的业务请求都会被拒绝
+	done := make(chan map[status.Code]int)
+	go func() { done <- sleepCalls(t, c, 2, 300) }()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		var resp server.HealthCheckResponse
+		if err := c.Call(ctx, server.HealthCheckMethod, &server.HealthCheckRequest{}, &resp); err != nil || resp.Status != server.StatusServing {
+			t.Fatalf("health check under load: %v %v", resp.Status, err)
+		}
+	}
+	if codes := <-done; codes[status.OK] != 1 || codes[status.ResourceExhausted] != 1 {
+		t.Fatalf("expected 1 OK and 1 ResourceExhausted, got %v", codes)
+	}
+}
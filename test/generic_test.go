@@ -0,0 +1,59 @@
+package test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+)
+
+func TestGenericHelpers(t *testing.T) {
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+
+	c, err := client.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	dc := client.NewDClient(client.NewServerDiscovery([]string{"tcp@" + lis.Addr().String()}, ""), client.RandomModel)
+	defer func() { _ = dc.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, caller := range []interface {
+		client.Caller
+		client.AsyncCaller
+	}{c, dc} {
+		reply, err := client.Invoke[*Argv, Reply](ctx, caller, "TestAdd.Add", &Argv{A: 1, B: 2})
+		if err != nil || reply.C != 3 {
+			t.Fatalf("Invoke: %v %v", reply, err)
+		}
+		ptr, err := client.Invoke[*Argv, *Reply](ctx, caller, "TestAdd.Add", &Argv{A: 2, B: 2})
+		if err != nil || ptr.C != 4 {
+			t.Fatalf("Invoke pointer reply: %v %v", ptr, err)
+		}
+
+		f := client.Async[*Argv, Reply](caller, "TestAdd.Add", &Argv{A: 3, B: 4})
+		<-f.Done()
+		for i := 0; i < 2; i++ {
+			if reply, err := f.Wait(ctx); err != nil || reply.C != 7 {
+				t.Fatalf("Future.Wait: %v %v", reply, err)
+			}
+		}
+		f = client.Async[*Argv, Reply](caller, "TestAdd.ReturnError", &Argv{})
+		if _, err := f.Wait(ctx); err == nil {
+			t.Fatal("Future.Wait should return the server error")
+		}
+	}
+
+	dead := client.NewDClient(client.NewServerDiscovery(nil, ""), client.RandomModel)
+	if _, err := client.Async[*Argv, Reply](dead, "TestAdd.Add", &Argv{}).Wait(ctx); err == nil {
+		t.Fatal("Async without servers should fail")
+	}
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tinyrpc/server"
+)
+
+func TestHealthWatchLongPoll(t *testing.T) {
+	s, addr := startServer(t, nil, &Sleeper{})
+	c := dialServer(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Wait 超过处理超时且状态不变时，在超时前返回当前状态而不是 DeadlineExceeded
+	start := time.Now()
+	var resp server.HealthCheckResponse
+	req := &server.HealthWatchRequest{Service: "Sleeper", LastStatus: server.StatusServing, Wait: 3 * time.Second}
+	if err := c.Call(ctx, "Health.Watch", req, &resp); err != nil || resp.Status != server.StatusServing {
+		t.Fatalf("Watch: %v %v", resp.Status, err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond || elapsed >= server.DefaultHandleTimeout {
+		t.Fatalf("Watch returned after %v", elapsed)
+	}
+
+	// 状态变化时立即返回新的状态
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.Health().SetServingStatus("Sleeper", server.StatusNotServing)
+	}()
+	if err := c.Call(ctx, "Health.Watch", req, &resp); err != nil || resp.Status != server.StatusNotServing {
+		t.Fatalf("Watch: %v %v, want NOT_SERVING", resp.Status, err)
+	}
+}
+
+func TestHealthWatchHandleTimeout(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithHandleTimeout(3 * time.Second)}, &Sleeper{})
+	c := dialServer(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 处理超时调大后可以等待更久
+	start := time.Now()
+	var resp server.HealthCheckResponse
+	req := &server.HealthWatchRequest{Service: "Sleeper", LastStatus: server.StatusServing, Wait: 1500 * time.Millisecond}
+	if err := c.Call(ctx, "Health.Watch", req, &resp); err != nil || resp.Status != server.StatusServing {
+		t.Fatalf("Watch: %v %v", resp.Status, err)
+	}
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Fatalf("Watch returned after %v", elapsed)
+	}
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"net"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+)
+
+// Sleeper 处理较慢的服务，用于让调用在连接上堆积
+type Sleeper struct{}
+
+func (s *Sleeper) Sleep(ms *int, reply *int) error {
+	time.Sleep(time.Duration(*ms) * time.Millisecond)
+	*reply = *ms
+	return nil
+}
+
+// startServer 创建注册了 services 的服务端，在 127.0.0.1 的随机端口上接受连接，测试结束时关闭 listener
+func startServer(t *testing.T, opts []server.Option, services ...interface{}) (*server.Server, string) {
+	s := server.NewServer(opts...)
+	for _, service := range services {
+		if err := s.Register(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+	t.Cleanup(func() { _ = lis.Close() })
+	return s, lis.Addr().String()
+}
+
+// dialServer 通过 tcp 连接 addr，测试结束时关闭客户端
+func dialServer(t *testing.T, addr string, opts ...client.Option) *client.Client {
+	c, err := client.Dial("tcp", addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/codec"
+	"tinyrpc/registry"
+	"tinyrpc/server"
+)
+
+func TestServeHTTP(t *testing.T) {
+	// RPC 和注册中心共用同一个端口
+	mux := http.NewServeMux()
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	s.Mount(mux)
+	registry.NewRegistry(time.Minute).Mount(mux)
+	hs := httptest.NewServer(mux)
+	defer hs.Close()
+	addr := strings.TrimPrefix(hs.URL, "http://")
+
+	c, err := client.DialHTTP("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+
+	registries := []string{hs.URL + registry.DefaultPath}
+	if err := server.SendHeartbeatTo(registries, "http@"+addr); err != nil {
+		t.Fatal(err)
+	}
+	dc := client.NewDClient(client.NewRegistryDiscovery(registries, 0), client.RoundRobinModel)
+	defer func() { _ = dc.Close() }()
+	if err := dc.Call(ctx, "TestAdd.Add", &Argv{A: 2, B: 3}, &reply); err != nil || reply.C != 5 {
+		t.Fatalf("discovery call reply %d err %v", reply.C, err)
+	}
+
+	resp, err := http.Get(hs.URL + server.DefaultRPCPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status %d", resp.StatusCode)
+	}
+	if _, err := client.DialHTTPPath("tcp", addr, "/not-found", time.Second); err == nil {
+		t.Fatal("dial of an unknown path should fail")
+	}
+
+	// 调试页面需要单独挂载
+	resp, err = http.Get(hs.URL + server.DefaultDebugPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("debug page should not be mounted by Mount, status %d", resp.StatusCode)
+	}
+	s.MountDebug(mux)
+	resp, err = http.Get(hs.URL + server.DefaultDebugPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	for _, want := range []string{
+		"Service TestAdd",
+		"<td>*test.Argv</td><td>*test.Reply</td><td align=\"right\">2</td>",
+		"Connections (2)",
+		"<td>gob</td><td>false</td>",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("debug page missing %q:\n%s", want, page)
+		}
+	}
+}
+
+func TestServeHTTPPipelined(t *testing.T) {
+	mux := http.NewServeMux()
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	s.Mount(mux)
+	hs := httptest.NewServer(mux)
+	defer hs.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(hs.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	// CONNECT、握手参数和请求在一次写入中发送，服务端不能丢弃缓冲中的数据
+	var buf bytes.Buffer
+	buf.WriteString("CONNECT " + codec.DefaultRPCPath + " HTTP/1.0\n\n")
+	enc := json.NewEncoder(&buf)
+	_ = enc.Encode(codec.ConArgs{Protocol: "rpc", CodecType: codec.JsonType})
+	_ = enc.Encode(codec.Header{ServiceMethod: "TestAdd.Add", Num: 1})
+	_ = enc.Encode(Argv{A: 1, B: 2})
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil || resp.Status != codec.Connected {
+		t.Fatalf("connect: %v %v", resp, err)
+	}
+	dec := json.NewDecoder(br)
+	var header codec.Header
+	var reply Reply
+	if err := dec.Decode(&header); err != nil || header.Error != "" {
+		t.Fatalf("header %+v err %v", header, err)
+	}
+	if err := dec.Decode(&reply); err != nil || reply.C != 3 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+}
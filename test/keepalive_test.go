@@ -0,0 +1,118 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+	"tinyrpc/status"
+)
+
+func waitUnavailable(c *client.Client, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for c.IsAvailable() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return !c.IsAvailable()
+}
+
+func TestKeepaliveDeadServer(t *testing.T) {
+	// 服务端接受连接后不再回复任何数据，模拟半开的连接
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lis.Close() }()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	c, err := client.Dial("tcp", lis.Addr().String(), client.WithKeepalive(50*time.Millisecond, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	var reply Reply
+	err = c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply)
+	if status.CodeOf(err) != status.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("dead connection detected after %v", elapsed)
+	}
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); status.CodeOf(err) != status.Unavailable {
+		t.Fatalf("calls after keepalive timeout should fail with Unavailable, got %v", err)
+	}
+}
+
+func TestKeepaliveHealthyConnection(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithMinPingInterval(10 * time.Millisecond)}, &TestAdd{}, &Sleeper{})
+	c, err := client.Dial("tcp", addr, client.WithKeepalive(20*time.Millisecond, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	time.Sleep(200 * time.Millisecond)
+	if !c.IsAvailable() {
+		t.Fatal("connection answering pings should stay available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+}
+
+func TestTooManyPings(t *testing.T) {
+	_, addr := startServer(t, nil, &TestAdd{}, &Sleeper{})
+	c, err := client.Dial("tcp", addr, client.WithKeepalive(10*time.Millisecond, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	if !waitUnavailable(c, time.Second) {
+		t.Fatal("server should close a connection that pings too often")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); status.CodeOf(err) != status.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithIdleTimeout(100 * time.Millisecond)}, &TestAdd{}, &Sleeper{})
+	c, err := client.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// 处理中的请求不算空闲
+	ms, slept := 250, 0
+	if err := c.Call(ctx, "Sleeper.Sleep", &ms, &slept); err != nil || slept != ms {
+		t.Fatalf("slow call: reply %d err %v", slept, err)
+	}
+	if !waitUnavailable(c, time.Second) {
+		t.Fatal("idle connection should be closed")
+	}
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); status.CodeOf(err) != status.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+}
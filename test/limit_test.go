@@ -0,0 +1,193 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/codec"
+	"tinyrpc/metrics"
+	"tinyrpc/server"
+	"tinyrpc/status"
+)
+
+// Blob 收发指定大小的数据
+type Blob struct{}
+
+func (b *Blob) Size(data *[]byte, n *int) error {
+	*n = len(*data)
+	return nil
+}
+
+func (b *Blob) Make(n *int, data *[]byte) error {
+	*data = make([]byte, *n)
+	return nil
+}
+
+func TestServerMaxRequestSize(t *testing.T) {
+	for _, codecType := range []codec.Type{codec.GobType, codec.JsonType} {
+		_, addr := startServer(t, []server.Option{server.WithMaxRequestSize(1024)}, &Blob{})
+		c, err := client.Dial("tcp", addr, client.WithCodec(codecType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		small, n := make([]byte, 100), 0
+		if err := c.Call(ctx, "Blob.Size", &small, &n); err != nil || n != 100 {
+			t.Fatalf("%s: small request: n %d err %v", codecType, n, err)
+		}
+		large := make([]byte, 64<<10)
+		if err := c.Call(ctx, "Blob.Size", &large, &n); status.CodeOf(err) != status.ResourceExhausted {
+			t.Fatalf("%s: expected ResourceExhausted, got %v", codecType, err)
+		}
+		if !waitUnavailable(c, time.Second) {
+			t.Fatalf("%s: connection should be closed after an oversized request", codecType)
+		}
+		cancel()
+		_ = c.Close()
+	}
+}
+
+func TestServerMaxResponseSize(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithMaxResponseSize(1024)}, &Blob{})
+	c, err := client.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var data []byte
+	n := 64 << 10
+	if err := c.Call(ctx, "Blob.Make", &n, &data); status.CodeOf(err) != status.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	// 响应在发送前被拒绝，连接仍然可用
+	n = 100
+	if err := c.Call(ctx, "Blob.Make", &n, &data); err != nil || len(data) != 100 {
+		t.Fatalf("len %d err %v", len(data), err)
+	}
+}
+
+func TestClientMessageSizeLimits(t *testing.T) {
+	_, addr := startServer(t, nil, &Blob{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, codecType := range []codec.Type{codec.GobType, codec.JsonType} {
+		c, err := client.Dial("tcp", addr, client.WithCodec(codecType), client.WithMaxRequestSize(1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = c.Close() }()
+		// 第一次发送 []byte 就超过限制，gob 的类型定义仍然需要发送，之后的调用才能正常解码
+		large, n := make([]byte, 64<<10), 0
+		if err := c.Call(ctx, "Blob.Size", &large, &n); status.CodeOf(err) != status.ResourceExhausted {
+			t.Fatalf("%s: expected ResourceExhausted, got %v", codecType, err)
+		}
+		small := make([]byte, 100)
+		if err := c.Call(ctx, "Blob.Size", &small, &n); err != nil || n != 100 {
+			t.Fatalf("%s: request after a rejected one: n %d err %v", codecType, n, err)
+		}
+
+		// 批量调用中超过限制的请求单独返回错误，其余的请求正常发送
+		batch := c.NewBatch()
+		sizes := []int{0, 0, 0}
+		calls := []*client.Call{
+			batch.Add("Blob.Size", &small, &sizes[0], nil),
+			batch.Add("Blob.Size", &large, &sizes[1], nil),
+			batch.Add("Blob.Size", &small, &sizes[2], nil),
+		}
+		_ = batch.Do(ctx)
+		if calls[0].Error != nil || calls[2].Error != nil || sizes[0] != 100 || sizes[2] != 100 {
+			t.Fatalf("%s: batch replies %v errors %v %v", codecType, sizes, calls[0].Error, calls[2].Error)
+		}
+		if status.CodeOf(calls[1].Error) != status.ResourceExhausted {
+			t.Fatalf("%s: expected ResourceExhausted in batch, got %v", codecType, calls[1].Error)
+		}
+		if !c.IsAvailable() {
+			t.Fatalf("%s: request rejected before sending should keep the connection", codecType)
+		}
+	}
+
+	c2, err := client.Dial("tcp", addr, client.WithMaxResponseSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c2.Close() }()
+	var data []byte
+	n := 100
+	if err := c2.Call(ctx, "Blob.Make", &n, &data); err != nil || len(data) != 100 {
+		t.Fatalf("len %d err %v", len(data), err)
+	}
+	n = 64 << 10
+	if err := c2.Call(ctx, "Blob.Make", &n, &data); status.CodeOf(err) != status.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if !waitUnavailable(c2, time.Second) {
+		t.Fatal("connection should be closed after an oversized response")
+	}
+}
+
+func TestMessageSizeUnlimitedByDefault(t *testing.T) {
+	// 没有配置时两端都不限制消息大小
+	_, addr := startServer(t, nil, &Blob{})
+	c := dialServer(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	large, n := make([]byte, 8<<20), 0
+	if err := c.Call(ctx, "Blob.Size", &large, &n); err != nil || n != len(large) {
+		t.Fatalf("large request: n %d err %v", n, err)
+	}
+	var data []byte
+	n = 8 << 20
+	if err := c.Call(ctx, "Blob.Make", &n, &data); err != nil || len(data) != n {
+		t.Fatalf("large response: len %d err %v", len(data), err)
+	}
+}
+
+func TestMessageSizeMetrics(t *testing.T) {
+	serverMetrics, clientMetrics := metrics.NewRegistry(), metrics.NewRegistry()
+	_, addr := startServer(t, []server.Option{server.WithMetrics(serverMetrics)}, &Blob{})
+	c, err := client.Dial("tcp", addr, client.WithMetrics(clientMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	data, n := make([]byte, 2000), 0
+	if err := c.Call(ctx, "Blob.Size", &data, &n); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+
+	expose := func(r *metrics.Registry) string {
+		var buf bytes.Buffer
+		_, _ = r.WriteTo(&buf)
+		return buf.String()
+	}
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(expose(serverMetrics), `tinyrpc_server_connections 0`) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, want := range []string{
+		`tinyrpc_server_request_size_bytes_bucket{service="Blob",method="Size",le="1024"} 0`,
+		`tinyrpc_server_request_size_bytes_bucket{service="Blob",method="Size",le="4096"} 1`,
+		`tinyrpc_server_response_size_bytes_count{service="Blob",method="Size"} 1`,
+	} {
+		if got := expose(serverMetrics); !strings.Contains(got, want) {
+			t.Errorf("server metrics missing %q:\n%s", want, got)
+		}
+	}
+	for _, want := range []string{
+		`tinyrpc_client_request_size_bytes_bucket{service="Blob",method="Size",le="1024"} 0`,
+		`tinyrpc_client_request_size_bytes_bucket{service="Blob",method="Size",le="4096"} 1`,
+		`tinyrpc_client_response_size_bytes_count{service="Blob",method="Size"} 1`,
+	} {
+		if got := expose(clientMetrics); !strings.Contains(got, want) {
+			t.Errorf("client metrics missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/logging"
+	"tinyrpc/server"
+)
+
+// syncBuffer 日志可能在多个协程中并发写入
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogger(t *testing.T) {
+	var serverLog, clientLog syncBuffer
+	s := server.NewServer(server.WithLogger(logging.New(&serverLog, logging.LevelDebug)))
+	_ = s.Register(&TestAdd{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+
+	c, err := client.Dial("tcp", lis.Addr().String(), client.WithLogger(logging.New(&clientLog, logging.LevelWarn)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+
+	for _, want := range []string{
+		`level=INFO msg="server: register service" service=TestAdd`,
+		`level=DEBUG msg="server: register method" service=TestAdd method=Add`,
+		`level=DEBUG msg="server: read request" method=TestAdd.Add num=1`,
+	} {
+		if !strings.Contains(serverLog.String(), want) {
+			t.Errorf("server log missing %q:\n%s", want, serverLog.String())
+		}
+	}
+	// 客户端只输出 Warn 及以上的日志，主动关闭连接不是错误
+	if clientLog.String() != "" {
+		t.Errorf("client log:\n%s", clientLog.String())
+	}
+}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/metadata"
+	"tinyrpc/server"
+)
+
+type Echo struct{}
+
+// Caller 返回请求元数据中的调用方，并通过 trailer 返回 trace id
+func (e *Echo) Caller(ctx context.Context, argv string, reply *string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	*reply = argv + " from " + md.Get("caller")
+	return server.SetTrailer(ctx, metadata.Pairs("trace-id", md.Get("trace-id")))
+}
+
+func TestMetadata(t *testing.T) {
+	var seen []string
+	requireTenant := func(ctx context.Context, info *server.RequestInfo, argv interface{}, reply interface{}, next server.Handler) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		seen = append(seen, info.ServiceMethod)
+		if md.Get("tenant") == "" {
+			return errors.New("missing tenant")
+		}
+		_ = server.SetTrailer(ctx, metadata.Pairs("tenant", md.Get("tenant")))
+		return next(ctx, argv, reply)
+	}
+	s := server.NewServer(server.WithInterceptor(requireTenant))
+	_ = s.Register(&Echo{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+
+	c, err := client.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var reply string
+	if err := c.Call(ctx, "Echo.Caller", "hi", &reply); err == nil || err.Error() != "missing tenant" {
+		t.Fatalf("call without tenant: %v", err)
+	}
+
+	var trailer metadata.MD
+	callCtx := metadata.NewOutgoingContext(ctx, metadata.Pairs("Caller", "billing", "tenant", "t1"))
+	callCtx = metadata.AppendToOutgoingContext(callCtx, "trace-id", "abc")
+	if err := c.Call(client.WithTrailer(callCtx, &trailer), "Echo.Caller", "hi", &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hi from billing" {
+		t.Fatalf("reply %q", reply)
+	}
+	if trailer.Get("trace-id") != "abc" || trailer.Get("tenant") != "t1" {
+		t.Fatalf("trailer %v", trailer)
+	}
+
+	call := <-c.GoContext(callCtx, "Echo.Caller", "async", &reply, nil).Done
+	if call.Error != nil || call.Trailer.Get("trace-id") != "abc" {
+		t.Fatalf("async call: %v %v", call.Error, call.Trailer)
+	}
+	if len(seen) != 3 || seen[0] != "Echo.Caller" {
+		t.Fatalf("interceptor saw %v", seen)
+	}
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/metrics"
+	"tinyrpc/server"
+)
+
+func TestMetrics(t *testing.T) {
+	serverMetrics, clientMetrics := metrics.NewRegistry(), metrics.NewRegistry()
+	s := server.NewServer(server.WithMetrics(serverMetrics))
+	_ = s.Register(&TestAdd{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+
+	c, err := client.Dial("tcp", lis.Addr().String(), client.WithMetrics(clientMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	for i := 0; i < 3; i++ {
+		if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = c.Call(ctx, "TestAdd.Missing", &Argv{}, &reply)
+	_ = c.Close()
+
+	expose := func(r *metrics.Registry) string {
+		var buf bytes.Buffer
+		_, _ = r.WriteTo(&buf)
+		return buf.String()
+	}
+	// 服务端在回复之后才更新指标，等待最后一个请求的指标
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(expose(serverMetrics), `tinyrpc_server_connections 0`) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := expose(serverMetrics)
+	for _, want := range []string{
+		`tinyrpc_server_requests_total{service="TestAdd",method="Add",code="OK"} 3`,
+		`tinyrpc_server_requests_total{service="unknown",method="unknown",code="NotFound"} 1`,
+		`tinyrpc_server_request_duration_seconds_count{service="TestAdd",method="Add"} 3`,
+		`tinyrpc_server_in_flight_requests{service="TestAdd",method="Add"} 0`,
+		`tinyrpc_server_connections 0`,
+		`tinyrpc_server_received_bytes_total `,
+		`tinyrpc_server_sent_bytes_total `,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("server metrics missing %q:\n%s", want, got)
+		}
+	}
+
+	got = expose(clientMetrics)
+	for _, want := range []string{
+		`tinyrpc_client_requests_total{service="TestAdd",method="Add",code="OK"} 3`,
+		`tinyrpc_client_requests_total{service="TestAdd",method="Missing",code="NotFound"} 1`,
+		`tinyrpc_client_request_duration_seconds_count{service="TestAdd",method="Add"} 3`,
+		`tinyrpc_client_pending_calls 0`,
+		`tinyrpc_client_connections 0`,
+		`tinyrpc_client_sent_bytes_total `,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("client metrics missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/status"
+)
+
+func TestMaxPendingCalls(t *testing.T) {
+	_, addr := startServer(t, nil, &TestAdd{}, &Sleeper{})
+	c, err := client.Dial("tcp", addr, client.WithMaxPendingCalls(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	ms := 300
+	calls := []*client.Call{
+		c.Go("Sleeper.Sleep", &ms, new(int), nil),
+		c.Go("Sleeper.Sleep", &ms, new(int), nil),
+	}
+	if n := c.PendingCalls(); n != 2 {
+		t.Fatalf("expected 2 pending calls, got %d", n)
+	}
+	// Go 不等待，直接失败
+	call := c.Go("Sleeper.Sleep", &ms, new(int), nil)
+	<-call.Done
+	if status.CodeOf(call.Error) != status.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", call.Error)
+	}
+
+	// Call 等待到 ctx 结束
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	var reply Reply
+	if err := c.Call(short, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); status.CodeOf(err) != status.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	// 有调用结束后 Call 继续执行
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("call should wait for a pending call to finish, waited %v", elapsed)
+	}
+	for _, call := range calls {
+		<-call.Done
+		if call.Error != nil {
+			t.Fatal(call.Error)
+		}
+	}
+	if n := c.PendingCalls(); n != 0 {
+		t.Fatalf("expected no pending calls, got %d", n)
+	}
+}
+
+func TestMaxPendingCallsReleasedOnClose(t *testing.T) {
+	_, addr := startServer(t, nil, &TestAdd{}, &Sleeper{})
+	c, err := client.Dial("tcp", addr, client.WithMaxPendingCalls(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := 300
+	call := c.Go("Sleeper.Sleep", &ms, new(int), nil)
+	_ = c.Close()
+	<-call.Done
+	if call.Error == nil {
+		t.Fatal("pending call should fail when the client is closed")
+	}
+	if n := c.PendingCalls(); n != 0 {
+		t.Fatalf("expected no pending calls after close, got %d", n)
+	}
+}
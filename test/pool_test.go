@@ -0,0 +1,146 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+	"tinyrpc/status"
+)
+
+func TestPoolGrowAndShrink(t *testing.T) {
+	_, addr := startServer(t, nil, &TestAdd{}, &Sleeper{})
+	pool, err := client.NewPool("tcp@"+addr,
+		client.WithPoolSize(1, 3),
+		client.WithGrowThreshold(1),
+		client.WithIdleTimeout(300*time.Millisecond),
+		client.WithHealthCheck(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pool.Close() }()
+	if pool.Len() != 1 {
+		t.Fatalf("pool should start with min connections, got %d", pool.Len())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var group sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			ms, reply := 200, 0
+			if err := pool.Call(ctx, "Sleeper.Sleep", &ms, &reply); err != nil || reply != ms {
+				t.Errorf("reply %d err %v", reply, err)
+			}
+		}()
+		time.Sleep(5 * time.Millisecond)
+	}
+	group.Wait()
+	if n := pool.Len(); n != 3 {
+		t.Fatalf("pool should grow to max under load, got %d", n)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Len() > 1 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := pool.Len(); n != 1 {
+		t.Fatalf("idle connections should shrink to min, got %d", n)
+	}
+	var reply Reply
+	if err := pool.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+
+	_ = pool.Close()
+	if err := pool.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err == nil {
+		t.Fatal("call on a closed pool should fail")
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	// 记录发起健康检查的连接，连接被关闭并重新建立后地址会变化
+	var mu sync.Mutex
+	peers := make(map[string]bool)
+	s, addr := startServer(t, []server.Option{server.WithInterceptor(
+		func(ctx context.Context, info *server.RequestInfo, argv interface{}, reply interface{}, next server.Handler) error {
+			if p, ok := server.PeerFromContext(ctx); ok && info.ServiceMethod == server.HealthCheckMethod {
+				mu.Lock()
+				peers[p.Addr.String()] = true
+				mu.Unlock()
+			}
+			return next(ctx, argv, reply)
+		})}, &TestAdd{})
+	pool, err := client.NewPool("tcp@"+addr,
+		client.WithPoolSize(2, 4),
+		client.WithHealthCheck(50*time.Millisecond, 100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pool.Close() }()
+	if pool.Len() != 2 {
+		t.Fatalf("pool should start with min connections, got %d", pool.Len())
+	}
+
+	s.Health().SetServingStatus("", server.StatusNotServing)
+	redialed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(peers) > 2
+	}
+	deadline := time.Now().Add(time.Second)
+	for !redialed() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !redialed() {
+		t.Fatal("unhealthy connections should be closed and redialed")
+	}
+
+	// 服务端恢复后，不需要调用连接数也会回到 min
+	s.Health().SetServingStatus("", server.StatusServing)
+	deadline = time.Now().Add(time.Second)
+	for pool.Len() != 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := pool.Len(); n != 2 {
+		t.Fatalf("pool should refill to min, got %d", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := pool.Call(ctx, "TestAdd.Add", &Argv{A: 2, B: 3}, &reply); err != nil || reply.C != 5 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+
+	if _, err := client.NewPool("tcp@"+addr, client.WithPoolSize(3, 2)); err == nil {
+		t.Fatal("min larger than max should be rejected")
+	}
+}
+
+func TestPoolDialBoundedByContext(t *testing.T) {
+	// listener 不接受连接，同步建立连接时应当在 ctx 到期后返回
+	lis, err := server.ListenInProcess("pool-idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lis.Close() }()
+	pool, err := client.NewPool("inproc@pool-idle", client.WithPoolSize(0, 1), client.WithHealthCheck(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pool.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var reply Reply
+	if err := pool.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); status.CodeOf(err) != status.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("dial took %v", elapsed)
+	}
+}
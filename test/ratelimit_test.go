@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+	"tinyrpc/status"
+)
+
+func TestMethodRateLimit(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithMethodRateLimit("TestAdd.Add", 5, 2)}, &TestAdd{}, &Sleeper{})
+	c := dialServer(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	for i := 0; i < 2; i++ {
+		if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil {
+			t.Fatalf("call %d within the burst: %v", i, err)
+		}
+	}
+	err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply)
+	if status.CodeOf(err) != status.RateLimited {
+		t.Fatalf("expected RateLimited, got %v", err)
+	}
+	if wait := status.RetryAfterOf(err); wait <= 0 || wait > 200*time.Millisecond {
+		t.Fatalf("unexpected retry hint %v", wait)
+	}
+	// 其他方法不受影响
+	ms, n := 1, 0
+	if err := c.Call(ctx, "Sleeper.Sleep", &ms, &n); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientRateLimitSharedByPeer(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithClientRateLimit(1, 1)}, &TestAdd{}, &Sleeper{})
+	c1 := dialServer(t, addr)
+	c2 := dialServer(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := c1.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	// 同一主机的连接共享限额
+	if err := c2.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); status.CodeOf(err) != status.RateLimited {
+		t.Fatalf("expected RateLimited, got %v", err)
+	}
+}
+
+func TestRetryPolicyHonorsRetryAfter(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithRateLimit(5, 1)}, &TestAdd{}, &Sleeper{})
+	c, err := client.Dial("tcp", addr, client.WithRetryPolicy(client.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("call should succeed after retrying: reply %d err %v", reply.C, err)
+	}
+	// 服务端建议的等待时间长于 Backoff
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("retry should wait for the server hint, waited %v", elapsed)
+	}
+
+	// 截止时间早于建议的等待时间时直接返回错误
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	if err := c.Call(short, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); status.CodeOf(err) != status.RateLimited {
+		t.Fatalf("expected RateLimited, got %v", err)
+	}
+}
+
+func TestRateLimitExemptsBuiltinServices(t *testing.T) {
+	_, addr := startServer(t, []server.Option{server.WithRateLimit(1, 1), server.WithClientRateLimit(1, 1)}, &TestAdd{})
+	c := dialServer(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); status.CodeOf(err) != status.RateLimited {
+		t.Fatalf("expected RateLimited, got %v", err)
+	}
+	// 令牌用尽后健康检查仍然可以调用
+	for i := 0; i < 5; i++ {
+		var resp server.HealthCheckResponse
+		if err := c.Call(ctx, server.HealthCheckMethod, &server.HealthCheckRequest{}, &resp); err != nil || resp.Status != server.StatusServing {
+			t.Fatalf("health check %d: status %v err %v", i, resp.Status, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+)
+
+func TestReflectionOverRPC(t *testing.T) {
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+
+	c, err := client.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var services server.ListServicesResponse
+	if err := c.Call(ctx, "Reflection.ListServices", &server.ListServicesRequest{}, &services); err != nil {
+		t.Fatal(err)
+	}
+	if len(services.Services) != 3 {
+		t.Fatalf("services %v", services.Services)
+	}
+	var desc server.ServiceDescriptor
+	if err := c.Call(ctx, "Reflection.DescribeService", &server.DescribeServiceRequest{Service: "TestAdd"}, &desc); err != nil {
+		t.Fatal(err)
+	}
+	if len(desc.Methods) != 2 || desc.Methods[0].Name != "Add" || desc.Methods[0].Argv.Elem.Fields[0].Name != "A" {
+		t.Fatalf("descriptor %+v", desc)
+	}
+}
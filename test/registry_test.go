@@ -0,0 +1,92 @@
+package test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/registry"
+	"tinyrpc/server"
+)
+
+func TestRegistryFailover(t *testing.T) {
+	// 第一个注册中心地址不可用，服务端和客户端都应当切换到第二个
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	mux := http.NewServeMux()
+	registry.NewRegistry(time.Minute).Mount(mux)
+	live := httptest.NewServer(mux)
+	defer live.Close()
+	registries := []string{dead.URL + registry.DefaultPath, live.URL + registry.DefaultPath}
+
+	s := &server.Server{}
+	_ = s.Register(&TestAdd{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+	if err := server.SendHeartbeatTo(registries, "tcp@"+lis.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	dc := client.NewDClient(client.NewRegistryDiscovery(registries, 0), client.RoundRobinModel)
+	defer func() { _ = dc.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := dc.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.C != 3 {
+		t.Fatalf("reply %d, want 3", reply.C)
+	}
+
+	if err := server.Deregister(registries, "tcp@"+lis.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryDiscoveryRefreshDoesNotBlock(t *testing.T) {
+	// 第一个注册中心不响应，拉取只受单次请求的超时限制，拉取期间其他调用方使用旧的服务列表
+	hang := make(chan struct{})
+	stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-hang
+	}))
+	defer stuck.Close()
+	defer close(hang)
+	mux := http.NewServeMux()
+	r := registry.NewRegistry(time.Minute)
+	r.Mount(mux)
+	live := httptest.NewServer(mux)
+	defer live.Close()
+	registries := []string{stuck.URL + registry.DefaultPath, live.URL + registry.DefaultPath}
+	if err := server.SendHeartbeatTo(registries[1:], "tcp@127.0.0.1:2"); err != nil {
+		t.Fatal(err)
+	}
+
+	d := client.NewRegistryDiscovery(registries, 100*time.Millisecond)
+	_ = d.Update([]string{"tcp@127.0.0.1:1"})
+	time.Sleep(150 * time.Millisecond)
+
+	start := time.Now()
+	refreshed := make(chan error, 1)
+	go func() { refreshed <- d.Refresh() }()
+	time.Sleep(50 * time.Millisecond)
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 || servers[0] != "tcp@127.0.0.1:1" {
+		t.Fatalf("GetAll during refresh: %v %v", servers, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("GetAll blocked for %v", elapsed)
+	}
+	if err := <-refreshed; err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("refresh took %v", elapsed)
+	}
+	if servers, _ := d.GetAll(); len(servers) != 1 || servers[0] != "tcp@127.0.0.1:2" {
+		t.Fatalf("servers after refresh: %v", servers)
+	}
+}
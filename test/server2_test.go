@@ -12,6 +12,8 @@ import (
 	"tinyrpc/server"
 )
 
+//go:generate go run ../cmd/tinyrpc-gen -type TestAdd -output testadd_rpc_test.go
+
 type TestAdd struct {
 }
 
@@ -38,11 +40,11 @@ func TestServer2(t *testing.T) {
 		log.Println("register error:", err)
 		return
 	}
-	lis, _ := net.Listen("tcp", ":5000")
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
 	go func() {
 		server.Accept(lis)
 	}()
-	c, err := client.Dial("tcp", ":5000")
+	c, err := client.Dial("tcp", lis.Addr().String())
 	if err != nil {
 		log.Println("Dial失败")
 		return
@@ -61,9 +63,10 @@ func TestServer2(t *testing.T) {
 			argv := &Argv{A: 1, B: i}
 			var reply Reply
 
-			ctx, _ := context.WithTimeout(context.Background(), time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
 			if err := c.Call(ctx, "TestAdd.Add", argv, &reply); err != nil {
-				log.Println("call error:", err)
+				t.Error("call error:", err)
 				return
 			}
 			log.Println("recieve :", reply)
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+)
+
+func TestGeneratedClient(t *testing.T) {
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+
+	c, err := client.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stub := NewTestAddClient(c)
+	reply, err := stub.Add(ctx, &Argv{A: 2, B: 3})
+	if err != nil || reply.C != 5 {
+		t.Fatalf("Add: %v %v", reply, err)
+	}
+	if _, err := stub.ReturnError(ctx, Argv{}); err == nil || err.Error() != "test : return error" {
+		t.Fatalf("ReturnError: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+// Code generated by tinyrpc-gen. DO NOT EDIT.
+
+package test
+
+import (
+	"context"
+	"tinyrpc/client"
+)
+
+// TestAddServer 服务端需要实现的方法
+type TestAddServer interface {
+	Add(argv *Argv, reply *Reply) error
+	ReturnError(argv Argv, reply *Reply) error
+}
+
+var _ TestAddServer = (*TestAdd)(nil)
+
+// TestAddClient 调用 TestAdd 服务的客户端
+type TestAddClient struct {
+	c client.Caller
+}
+
+func NewTestAddClient(c client.Caller) *TestAddClient {
+	return &TestAddClient{c: c}
+}
+
+func (c *TestAddClient) Add(ctx context.Context, argv *Argv) (*Reply, error) {
+	reply := new(Reply)
+	if err := c.c.Call(ctx, "TestAdd.Add", argv, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *TestAddClient) ReturnError(ctx context.Context, argv Argv) (*Reply, error) {
+	reply := new(Reply)
+	if err := c.c.Call(ctx, "TestAdd.ReturnError", argv, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
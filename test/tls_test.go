@@ -0,0 +1,210 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"tinyrpc/auth"
+	"tinyrpc/client"
+	"tinyrpc/server"
+)
+
+// testCA 测试用的自签名 CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tinyrpc test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue 签发证书，返回 PEM 编码的证书和私钥
+func (ca *testCA) issue(t *testing.T, serial int64, name string, roles ...string) (certPEM []byte, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name, OrganizationalUnit: roles},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) keyPair(t *testing.T, name string, roles ...string) tls.Certificate {
+	certPEM, keyPEM := ca.issue(t, 2, name, roles...)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// PeerName 返回 mTLS 客户端证书中的名称以及由证书得到的调用方
+func (w *WhoAmI) PeerName(ctx context.Context, argv string, reply *string) error {
+	peer, ok := server.PeerFromContext(ctx)
+	if !ok || peer.TLS == nil || len(peer.TLS.PeerCertificates) == 0 {
+		return errors.New("no peer certificate")
+	}
+	p, _ := auth.FromContext(ctx)
+	*reply = peer.TLS.PeerCertificates[0].Subject.CommonName + "/" + p.Name
+	return nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	s := server.NewServer()
+	_ = s.Register(&WhoAmI{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.ServeTLS(lis, &tls.Config{
+		Certificates: []tls.Certificate{ca.keyPair(t, "server")},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	defer func() { _ = lis.Close() }()
+	addr := lis.Addr().String()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c, err := client.DialTLS("tcp", addr, &tls.Config{
+		RootCAs:      ca.pool,
+		Certificates: []tls.Certificate{ca.keyPair(t, "billing", "admin")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	var reply string
+	if err := c.Call(ctx, "WhoAmI.PeerName", "", &reply); err != nil || reply != "billing/billing" {
+		t.Fatalf("reply %q err %v", reply, err)
+	}
+
+	// 没有客户端证书
+	// TLS 1.3 中服务端在客户端握手完成后才校验客户端证书，错误可能出现在第一次调用
+	if nc, err := client.DialTimeout("tcp", addr, time.Second, client.WithTLSConfig(&tls.Config{RootCAs: ca.pool})); err == nil {
+		if err := nc.Call(ctx, "WhoAmI.PeerName", "", &reply); err == nil {
+			t.Fatal("call without client certificate should fail")
+		}
+	}
+	// 不信任服务端证书
+	if _, err := client.DialTLS("tcp", addr, &tls.Config{}); err == nil {
+		t.Fatal("dial should fail with an unknown authority")
+	}
+	// 明文连接
+	if plain, err := client.DialTimeout("tcp", addr, time.Second); err == nil {
+		if err := plain.Call(ctx, "WhoAmI.PeerName", "", &reply); err == nil {
+			t.Fatal("plaintext call should fail")
+		}
+	}
+}
+
+func TestTLSCertificateReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	write := func(serial int64) {
+		certPEM, keyPEM := ca.issue(t, serial, "server")
+		if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(10)
+	reloader, err := auth.NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := server.NewServer()
+	_ = s.Register(&WhoAmI{})
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go s.ServeTLS(lis, &tls.Config{GetCertificate: reloader.GetCertificate})
+	defer func() { _ = lis.Close() }()
+
+	serial := func() int64 {
+		var got int64
+		c, err := client.DialTLS("tcp", lis.Addr().String(), &tls.Config{
+			RootCAs: ca.pool,
+			VerifyConnection: func(state tls.ConnectionState) error {
+				got = state.PeerCertificates[0].SerialNumber.Int64()
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = c.Close()
+		return got
+	}
+	if got := serial(); got != 10 {
+		t.Fatalf("serial %d", got)
+	}
+	write(11)
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := serial(); got != 11 {
+		t.Fatalf("serial after reload %d", got)
+	}
+
+	stop := reloader.Watch(10 * time.Millisecond)
+	defer stop()
+	write(12)
+	deadline := time.Now().Add(time.Second)
+	for serial() != 12 {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate not reloaded by Watch")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/metadata"
+	"tinyrpc/server"
+	"tinyrpc/trace"
+)
+
+// Frontend 调用下游的 TestAdd 服务
+type Frontend struct {
+	backend *client.Client
+}
+
+func (f *Frontend) Sum(ctx context.Context, argv *Argv, reply *Reply) error {
+	return f.backend.Call(ctx, "TestAdd.Add", argv, reply)
+}
+
+func TestTracing(t *testing.T) {
+	exporter := trace.NewInMemoryExporter()
+	tracer := trace.NewTracer(exporter)
+
+	backend := server.NewServer(server.WithTracer(tracer))
+	_ = backend.Register(&TestAdd{})
+	backendLis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go backend.Accept(backendLis)
+	defer func() { _ = backendLis.Close() }()
+	backendClient, err := client.Dial("tcp", backendLis.Addr().String(), client.WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = backendClient.Close() }()
+
+	frontend := server.NewServer(server.WithTracer(tracer))
+	_ = frontend.Register(&Frontend{backend: backendClient})
+	frontendLis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go frontend.Accept(frontendLis)
+	defer func() { _ = frontendLis.Close() }()
+	c, err := client.Dial("tcp", frontendLis.Addr().String(), client.WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// 调用方已经处于一个 trace 中
+	upstream := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, _ := trace.ParseTraceparent(upstream)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ctx = trace.ContextWithRemoteParent(ctx, sc)
+	var reply Reply
+	if err := c.Call(ctx, "Frontend.Sum", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+
+	// 服务端在回复之后才结束 span
+	deadline := time.Now().Add(time.Second)
+	for len(exporter.Spans()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	spans := make(map[string]*trace.SpanData)
+	for _, span := range exporter.Spans() {
+		spans[span.Kind+" "+span.Name] = span
+		if span.TraceID != sc.TraceID.String() || span.Code != "OK" {
+			t.Errorf("span %+v", span)
+		}
+	}
+	chain := []string{"client Frontend.Sum", "server Frontend.Sum", "client TestAdd.Add", "server TestAdd.Add"}
+	parent := sc.SpanID.String()
+	for _, name := range chain {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("missing span %q in %v", name, exporter.Spans())
+		}
+		if span.ParentSpanID != parent {
+			t.Fatalf("span %q has parent %s, want %s", name, span.ParentSpanID, parent)
+		}
+		parent = span.SpanID
+	}
+
+	// 注入 traceparent 时不能修改调用方 ctx 中的元数据
+	md := metadata.Pairs("caller", "test")
+	if err := c.Call(metadata.NewOutgoingContext(ctx, md), "Frontend.Sum", &Argv{A: 1, B: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if len(md) != 1 {
+		t.Fatalf("outgoing metadata modified: %v", md)
+	}
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/server"
+)
+
+func TestUnixSocket(t *testing.T) {
+	// Unix 域套接字的路径长度有限制，不使用较长的 t.TempDir()
+	dir, err := os.MkdirTemp("", "tinyrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	sock := filepath.Join(dir, "rpc.sock")
+
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Accept(lis)
+	defer func() { _ = lis.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := client.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+
+	c2, err := client.DialAddr("unix@"+sock, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c2.Close() }()
+	if err := c2.Call(ctx, "TestAdd.Add", &Argv{A: 2, B: 3}, &reply); err != nil || reply.C != 5 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+}
+
+func TestInProcess(t *testing.T) {
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	lis, err := s.ServeInProcess("adder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ListenInProcess("adder"); err == nil {
+		t.Fatal("listening on a name in use should fail")
+	}
+
+	c, err := client.DialInProcess("adder", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var group sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		group.Add(1)
+		go func(i int) {
+			defer group.Done()
+			var reply Reply
+			if err := c.Call(ctx, "TestAdd.Add", &Argv{A: i, B: i}, &reply); err != nil || reply.C != 2*i {
+				t.Errorf("reply %d err %v", reply.C, err)
+			}
+		}(i)
+	}
+	group.Wait()
+
+	dc := client.NewDClient(client.NewServerDiscovery([]string{"inproc@adder"}, ""), client.RandomModel)
+	defer func() { _ = dc.Close() }()
+	var reply Reply
+	if err := dc.Call(ctx, "TestAdd.Add", &Argv{A: 4, B: 5}, &reply); err != nil || reply.C != 9 {
+		t.Fatalf("discovery call reply %d err %v", reply.C, err)
+	}
+
+	_ = lis.Close()
+	if _, err := client.DialInProcess("adder", time.Second); err == nil {
+		t.Fatal("dial after the listener is closed should fail")
+	}
+	// 名称释放后可以重新使用
+	lis, err = s.ServeInProcess("adder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = lis.Close()
+}
+
+func TestInProcessDialTimeout(t *testing.T) {
+	// listener 存在但没有 Accept，连接在超时后失败
+	lis, err := server.ListenInProcess("idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lis.Close() }()
+	start := time.Now()
+	if _, err := client.DialAddr("inproc@idle", 50*time.Millisecond); err == nil {
+		t.Fatal("dial should time out when nobody accepts")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("dial took %v", elapsed)
+	}
+}
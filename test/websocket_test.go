@@ -0,0 +1,149 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tinyrpc/client"
+	"tinyrpc/codec"
+	"tinyrpc/server"
+	"tinyrpc/websocket"
+)
+
+func TestWebSocket(t *testing.T) {
+	mux := http.NewServeMux()
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	s.Mount(mux)
+	hs := httptest.NewServer(mux)
+	defer hs.Close()
+	addr := strings.TrimPrefix(hs.URL, "http://")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := client.DialWebSocket("ws://"+addr+server.DefaultWebSocketPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 1, B: 2}, &reply); err != nil || reply.C != 3 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+
+	c2, err := client.DialAddr("ws@"+addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c2.Close() }()
+	if err := c2.Call(ctx, "TestAdd.Add", &Argv{A: 2, B: 3}, &reply); err != nil || reply.C != 5 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+
+	if _, err := client.DialWebSocket("ws://"+addr+"/not-found", time.Second); err == nil {
+		t.Fatal("dial of an unknown path should fail")
+	}
+}
+
+// TestWebSocketRawMessages 按照浏览器的方式逐条发送 JSON 消息
+func TestWebSocketRawMessages(t *testing.T) {
+	mux := http.NewServeMux()
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	s.Mount(mux)
+	hs := httptest.NewServer(mux)
+	defer hs.Close()
+	addr := strings.TrimPrefix(hs.URL, "http://")
+
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := websocket.Client(raw, addr, server.DefaultWebSocketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	messages := []string{
+		`{"Protocol":"rpc","CodecType":"json"}`,
+		`{"Num":7,"ServiceMethod":"TestAdd.Add"}`,
+		`{"A":20,"B":22}`,
+	}
+	for _, msg := range messages {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := json.NewDecoder(conn)
+	var header codec.Header
+	if err := dec.Decode(&header); err != nil || header.Num != 7 || header.Error != "" {
+		t.Fatalf("header %+v err %v", header, err)
+	}
+	var reply Reply
+	if err := dec.Decode(&reply); err != nil || reply.C != 42 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+}
+
+func TestWebSocketTLS(t *testing.T) {
+	mux := http.NewServeMux()
+	s := server.NewServer()
+	_ = s.Register(&TestAdd{})
+	s.Mount(mux)
+	hs := httptest.NewTLSServer(mux)
+	defer hs.Close()
+	addr := strings.TrimPrefix(hs.URL, "https://")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(hs.Certificate())
+	c, err := client.DialWebSocket("wss://"+addr+server.DefaultWebSocketPath, time.Second,
+		client.WithTLSConfig(&tls.Config{RootCAs: pool}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply Reply
+	if err := c.Call(ctx, "TestAdd.Add", &Argv{A: 3, B: 4}, &reply); err != nil || reply.C != 7 {
+		t.Fatalf("reply %d err %v", reply.C, err)
+	}
+}
+
+// TestWebSocketOrigin 默认拒绝其他网站的页面发起的 WebSocket 连接
+func TestWebSocketOrigin(t *testing.T) {
+	for _, tt := range []struct {
+		opts []server.Option
+		want int
+	}{
+		{nil, http.StatusForbidden},
+		{[]server.Option{server.WithCheckOrigin(func(req *http.Request) bool { return true })}, http.StatusSwitchingProtocols},
+	} {
+		mux := http.NewServeMux()
+		server.NewServer(tt.opts...).Mount(mux)
+		hs := httptest.NewServer(mux)
+		req, _ := http.NewRequest(http.MethodGet, hs.URL+server.DefaultWebSocketPath, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Origin", "http://evil.example")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		hs.Close()
+		if resp.StatusCode != tt.want {
+			t.Fatalf("cross-origin handshake: %s, want %d", resp.Status, tt.want)
+		}
+	}
+}
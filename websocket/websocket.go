@@ -0,0 +1,384 @@
+/*
+websocket 基于标准库实现 RFC 6455 中 RPC 传输需要的部分：握手、数据帧的收发、ping/pong 和关闭。
+Conn 实现了 net.Conn，读取时把收到的消息拼接为字节流，每次 Write 发送一条消息，
+因此 json 编解码时每个 header 和 body 恰好是一条消息，浏览器可以直接 JSON.parse
+*/
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	finBit  = 0x80
+	maskBit = 0x80
+
+	// maxControlPayload 控制帧的负载不能超过 125 字节
+	maxControlPayload = 125
+
+	closeNormal        = 1000
+	closeProtocolError = 1002
+
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// ErrProtocol 对端发送了不符合协议的帧
+var ErrProtocol = errors.New("websocket error: protocol error")
+
+// Conn WebSocket 连接。Read 和 Write 分别只能由一个 goroutine 调用，两者可以并发，
+// 读取时自动回复 ping，收到 close 帧后回复 close 并返回 io.EOF
+type Conn struct {
+	net.Conn
+	br     *bufio.Reader
+	client bool // 客户端发送的帧需要掩码，服务端发送的帧不能有掩码
+
+	// 读取状态，只在 Read 中访问
+	remaining int64 // 当前帧还未读取的负载长度
+	masked    bool
+	mask      [4]byte
+	maskPos   int
+	inMessage bool // 分片消息还未结束
+	readErr   error
+
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{Conn: conn, br: br, client: client}
+}
+
+// Read 读取消息的负载，消息之间没有边界
+func (c *Conn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		if err := c.nextFrame(); err != nil {
+			c.readErr = err
+			return 0, err
+		}
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	if c.masked {
+		c.maskPos = maskBytes(c.mask, c.maskPos, p[:n])
+	}
+	c.remaining -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.readErr = err
+	}
+	return n, err
+}
+
+// nextFrame 读取下一个数据帧的头部，控制帧在这里处理完
+func (c *Conn) nextFrame() error {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return err
+	}
+	fin := head[0]&finBit != 0
+	opcode := head[0] & 0x0f
+	if head[0]&0x70 != 0 {
+		return c.fail("reserved bits set")
+	}
+	masked := head[1]&maskBit != 0
+	if masked == c.client {
+		return c.fail("unexpected frame masking")
+	}
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return err
+		}
+		if ext[0]&0x80 != 0 {
+			return c.fail("invalid payload length")
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return err
+		}
+	}
+
+	if opcode >= opClose {
+		if !fin || length > maxControlPayload {
+			return c.fail("invalid control frame")
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return err
+		}
+		if masked {
+			maskBytes(mask, 0, payload)
+		}
+		return c.control(opcode, payload)
+	}
+
+	switch opcode {
+	case opContinuation:
+		if !c.inMessage {
+			return c.fail("unexpected continuation frame")
+		}
+	case opText, opBinary:
+		if c.inMessage {
+			return c.fail("expected continuation frame")
+		}
+	default:
+		return c.fail("unknown opcode")
+	}
+	c.inMessage = !fin
+	c.remaining = length
+	c.masked = masked
+	c.mask = mask
+	c.maskPos = 0
+	return nil
+}
+
+func (c *Conn) control(opcode byte, payload []byte) error {
+	switch opcode {
+	case opPing:
+		return c.writeFrame(opPong, payload)
+	case opPong:
+		return nil
+	case opClose:
+		// 原样回复对端的状态码，之后不再发送数据
+		code := payload
+		if len(code) > 2 {
+			code = code[:2]
+		}
+		_ = c.writeClose(code)
+		return io.EOF
+	}
+	return c.fail("unknown opcode")
+}
+
+// fail 通知对端协议错误，返回包装了 ErrProtocol 的错误
+func (c *Conn) fail(reason string) error {
+	_ = c.writeClose(closePayload(closeProtocolError))
+	return fmt.Errorf("%w: %s", ErrProtocol, reason)
+}
+
+// Write 将 p 作为一条文本消息发送
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(opText, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, finBit|opcode)
+	var maskFlag byte
+	if c.client {
+		maskFlag = maskBit
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskFlag|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskFlag|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(append(frame, maskFlag|127), ext[:]...)
+	}
+	start := len(frame)
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start += 4
+		frame = append(frame, payload...)
+		maskBytes(mask, 0, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+func (c *Conn) writeClose(payload []byte) error {
+	return c.writeFrame(opClose, payload)
+}
+
+// Close 发送 close 帧后关闭底层连接
+func (c *Conn) Close() error {
+	_ = c.writeClose(closePayload(closeNormal))
+	return c.Conn.Close()
+}
+
+func closePayload(code uint16) []byte {
+	return []byte{byte(code >> 8), byte(code)}
+}
+
+// maskBytes 从 mask 的第 pos 个字节开始对 b 做异或，返回下一次的位置
+func maskBytes(mask [4]byte, pos int, b []byte) int {
+	for i := range b {
+		b[i] ^= mask[pos&3]
+		pos++
+	}
+	return pos & 3
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	_, _ = io.WriteString(h, key+acceptGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains 判断以逗号分隔的头部中是否含有 token，不区分大小写
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SameOrigin 没有 Origin 头部（非浏览器客户端）或 Origin 的主机与请求的 Host 相同时返回 true
+func SameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, req.Host)
+}
+
+// Upgrade 校验握手请求并接管连接，失败时已经向客户端返回了错误响应。
+// checkOrigin 返回 false 时拒绝握手，防止任意网页以访问者的身份跨站调用；为 nil 时使用 SameOrigin
+func Upgrade(w http.ResponseWriter, req *http.Request, checkOrigin func(req *http.Request) bool) (*Conn, error) {
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+	if req.Method != http.MethodGet {
+		http.Error(w, "websocket error: method must be GET", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket error: method must be GET")
+	}
+	if !headerContains(req.Header, "Connection", "upgrade") || !headerContains(req.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket error: not a websocket handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket error: not a websocket handshake")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket error: unsupported version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket error: unsupported version")
+	}
+	if !checkOrigin(req) {
+		http.Error(w, "websocket error: origin not allowed", http.StatusForbidden)
+		return nil, errors.New("websocket error: origin not allowed: " + req.Header.Get("Origin"))
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "websocket error: invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket error: invalid Sec-WebSocket-Key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket error: connection can't be hijacked", http.StatusInternalServerError)
+		return nil, errors.New("websocket error: connection can't be hijacked")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// http.Server 设置的超时对接管后的长连接不再适用
+	_ = conn.SetDeadline(time.Time{})
+	_, err = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+acceptKey(key)+"\r\n\r\n")
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return newConn(conn, brw.Reader, false), nil
+}
+
+// Client 在已经建立的连接上完成客户端握手，host 和 path 为请求的 Host 和路径
+func Client(conn net.Conn, host string, path string) (*Conn, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	_, err := io.WriteString(conn, "GET "+path+" HTTP/1.1\r\n"+
+		"Host: "+host+"\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, errors.New("websocket error: unexpected HTTP response: " + resp.Status)
+	}
+	if !headerContains(resp.Header, "Upgrade", "websocket") || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("websocket error: invalid handshake response")
+	}
+	// 服务端可能紧跟着握手响应发送数据，br 中已经缓存的部分由 Conn 继续读取
+	return newConn(conn, br, true), nil
+}
@@ -0,0 +1,202 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// rawFrame 按照客户端的格式手工构造一帧，用于测试分片和控制帧
+func rawFrame(opcode byte, fin bool, payload []byte, masked bool) []byte {
+	var head byte = opcode
+	if fin {
+		head |= finBit
+	}
+	frame := []byte{head, byte(len(payload))}
+	data := append([]byte(nil), payload...)
+	if masked {
+		frame[1] |= maskBit
+		mask := [4]byte{1, 2, 3, 4}
+		frame = append(frame, mask[:]...)
+		maskBytes(mask, 0, data)
+	}
+	return append(frame, data...)
+}
+
+// tcpPair 返回一对本地 TCP 连接，与 net.Pipe 不同，写入在对端读取前不会阻塞
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lis.Close() }()
+	b, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := lis.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestConnRoundTrip(t *testing.T) {
+	a, b := tcpPair(t)
+	server := newConn(a, nil, false)
+	client := newConn(b, nil, true)
+	defer func() { _ = client.Close() }()
+
+	for _, size := range []int{5, 300, 70000} {
+		msg := bytes.Repeat([]byte("x"), size)
+		go func() { _, _ = client.Write(msg) }()
+		got := make([]byte, size)
+		if _, err := io.ReadFull(server, got); err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("size %d: err %v", size, err)
+		}
+		go func() { _, _ = server.Write(msg) }()
+		if _, err := io.ReadFull(client, got); err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("size %d: err %v", size, err)
+		}
+	}
+
+	go func() { _ = server.Close() }()
+	if _, err := client.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("read after close: %v", err)
+	}
+}
+
+func TestFragmentsAndPing(t *testing.T) {
+	a, b := tcpPair(t)
+	server := newConn(a, nil, false)
+	defer func() { _ = server.Close() }()
+
+	frames := [][]byte{
+		rawFrame(opText, false, []byte("hel"), true),
+		rawFrame(opPing, true, []byte("p"), true),
+		rawFrame(opContinuation, true, []byte("lo"), true),
+	}
+	pong := make(chan []byte, 1)
+	go func() {
+		_, _ = b.Write(frames[0])
+		_, _ = b.Write(frames[1])
+		reply := make([]byte, 3)
+		_, _ = io.ReadFull(b, reply)
+		pong <- reply
+		_, _ = b.Write(frames[2])
+	}()
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(server, got); err != nil || string(got) != "hello" {
+		t.Fatalf("got %q err %v", got, err)
+	}
+	if reply := <-pong; !bytes.Equal(reply, []byte{finBit | opPong, 1, 'p'}) {
+		t.Fatalf("pong %v", reply)
+	}
+}
+
+func TestUnmaskedClientFrame(t *testing.T) {
+	a, b := tcpPair(t)
+	server := newConn(a, nil, false)
+	go func() {
+		_, _ = b.Write(rawFrame(opText, true, []byte("hi"), false))
+		_, _ = io.Copy(io.Discard, b)
+	}()
+	if _, err := server.Read(make([]byte, 2)); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("expected protocol error, got %v", err)
+	}
+	_ = server.Close()
+}
+
+func TestUpgrade(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.Copy(conn, conn)
+	}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("plain GET: %s", resp.Status)
+	}
+
+	addr := strings.TrimPrefix(ts.URL, "http://")
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := Client(raw, addr, "/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(conn, got); err != nil || string(got) != "ping" {
+		t.Fatalf("echo %q err %v", got, err)
+	}
+}
+
+func TestUpgradeChecksOrigin(t *testing.T) {
+	var allowed string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var check func(req *http.Request) bool
+		if allowed != "" {
+			check = func(req *http.Request) bool { return req.Header.Get("Origin") == allowed }
+		}
+		conn, err := Upgrade(w, req, check)
+		if err != nil {
+			return
+		}
+		_ = conn.Close()
+	}))
+	defer ts.Close()
+
+	handshake := func(origin string) int {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	tests := []struct {
+		allowed string
+		origin  string
+		want    int
+	}{
+		{"", "", http.StatusSwitchingProtocols},
+		{"", ts.URL, http.StatusSwitchingProtocols},
+		{"", "http://evil.example", http.StatusForbidden},
+		{"http://app.example", "http://app.example", http.StatusSwitchingProtocols},
+		{"http://app.example", ts.URL, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		allowed = tt.allowed
+		if got := handshake(tt.origin); got != tt.want {
+			t.Fatalf("allowed %q origin %q: status %d, want %d", tt.allowed, tt.origin, got, tt.want)
+		}
+	}
+}